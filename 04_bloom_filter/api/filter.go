@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/AVVKavvk/bloom_filter/bloomFilter"
+	"github.com/AVVKavvk/bloom_filter/service"
+	"github.com/labstack/echo/v4"
+)
+
+// MergeFilter godoc
+// @Summary      Merge a serialized filter into a named filter
+// @Description  ORs (op=union, default) or ANDs (op=intersect) the uploaded filter into :name. Both filters need the same m, k and hash. A union into an unknown name creates it, up to 32 filters and 32 MB in total.
+// @Tags         Filters
+// @Accept       octet-stream
+// @Produce      json
+// @Param        name  path      string  true   "Filter name, the /words filter is \"words\""
+// @Param        op    query     string  false  "union or intersect"
+// @Success      200   {object}  models.ResponseMerge
+// @Failure      400   {object}  map[string]string "Incompatible or malformed filter"
+// @Failure      404   {object}  map[string]string "Unknown filter"
+// @Failure      507   {object}  map[string]string "No room to register another filter"
+// @Router       /filters/{name}/merge [post]
+func MergeFilter(ctx echo.Context) error {
+	op := ctx.QueryParam("op")
+	if op == "" {
+		op = "union"
+	}
+
+	result, err := service.MergeFilterService(ctx.Param("name"), op, ctx.Request().Body)
+	if err != nil {
+		return filterError(err)
+	}
+	return ctx.JSON(200, result)
+}
+
+// ExportFilter godoc
+// @Summary      Download a named filter
+// @Description  Returns the filter in the binary format accepted by /filters/{name}/merge
+// @Tags         Filters
+// @Produce      octet-stream
+// @Param        name  path  string  true  "Filter name"
+// @Success      200
+// @Failure      404   {object}  map[string]string "Unknown filter"
+// @Router       /filters/{name}/export [get]
+func ExportFilter(ctx echo.Context) error {
+	ctx.Response().Header().Set(echo.HeaderContentType, echo.MIMEOctetStream)
+	if err := service.ExportFilterService(ctx.Param("name"), ctx.Response()); err != nil {
+		return filterError(err)
+	}
+	return nil
+}
+
+func filterError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrFilterNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, service.ErrMergeNotSupported),
+		errors.Is(err, service.ErrUnknownMergeOp),
+		errors.Is(err, bloomFilter.ErrIncompatible),
+		errors.Is(err, bloomFilter.ErrBadEncoding):
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	case errors.Is(err, bloomFilter.ErrRegistryFull):
+		return echo.NewHTTPError(http.StatusInsufficientStorage, err.Error())
+	}
+	return err
+}
@@ -1,6 +1,11 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
 	"github.com/AVVKavvk/bloom_filter/models"
 	"github.com/AVVKavvk/bloom_filter/service"
 	"github.com/labstack/echo/v4"
@@ -52,3 +57,133 @@ func CheckWeatherWordIsExist(ctx echo.Context) error {
 	}
 	return ctx.JSON(200, response)
 }
+
+// DeleteWord godoc
+// @Summary      Delete a word
+// @Description  Removes a word from the filter. Only the cuckoo filter backend supports deletes.
+// @Tags         BloomFilter
+// @Accept       json
+// @Produce      json
+// @Param        word  body      models.Word  true  "Word to delete"
+// @Success      200   {object}  models.ResponseDeleteWord
+// @Failure      400   {object}  map[string]string "Invalid request body or backend without delete support"
+// @Router       /words [delete]
+func DeleteWord(ctx echo.Context) error {
+	var word models.Word
+
+	if err := ctx.Bind(&word); err != nil {
+		return err
+	}
+	result, err := service.DeleteWordService(&word)
+	if errors.Is(err, service.ErrDeleteNotSupported) {
+		return echo.NewHTTPError(400, err.Error())
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
+
+// AddWords godoc
+// @Summary      Add many words to the Bloom Filter
+// @Description  Accepts {"words": [...]} as JSON, or NDJSON ({"word": "..."} per line) with Content-Type application/x-ndjson
+// @Tags         BloomFilter
+// @Accept       json
+// @Produce      json
+// @Param        words  body      models.Words  true  "Words to add"
+// @Success      201    {object}  models.ResponseBatch
+// @Failure      400    {object}  map[string]string "Invalid request body"
+// @Router       /words/batch [post]
+func AddWords(ctx echo.Context) error {
+	words, err := bindWords(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := service.AddWordsService(words)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(201, result)
+}
+
+// CheckWords godoc
+// @Summary      Check many words
+// @Description  Same body formats as /words/batch. Returns one result per word, in order. May return false positives.
+// @Tags         BloomFilter
+// @Accept       json
+// @Produce      json
+// @Param        words  body      models.Words  true  "Words to check"
+// @Success      200    {object}  models.ResponseBatch
+// @Failure      400    {object}  map[string]string "Invalid request body"
+// @Router       /words/check/batch [post]
+func CheckWords(ctx echo.Context) error {
+	words, err := bindWords(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := service.CheckWordsService(words)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
+
+// ImportWords godoc
+// @Summary      Bulk import a word list
+// @Description  Streams a plain text file with one word per line, either as the raw request body or as the multipart field "file"
+// @Tags         BloomFilter
+// @Accept       plain
+// @Accept       mpfd
+// @Produce      json
+// @Param        file  formData  file  false  "Word list, one word per line"
+// @Success      201   {object}  models.ResponseImport
+// @Failure      400   {object}  map[string]string "Invalid request body"
+// @Router       /words/import [post]
+func ImportWords(ctx echo.Context) error {
+	var body io.Reader = ctx.Request().Body
+
+	if strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			return echo.NewHTTPError(400, "missing multipart field \"file\"")
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		body = file
+	}
+
+	result, err := service.ImportWordsService(body)
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(201, result)
+}
+
+// bindWords reads either a JSON {"words": [...]} body or NDJSON lines of models.Word
+func bindWords(ctx echo.Context) ([]string, error) {
+	if !strings.HasPrefix(ctx.Request().Header.Get(echo.HeaderContentType), "application/x-ndjson") {
+		var words models.Words
+		if err := ctx.Bind(&words); err != nil {
+			return nil, err
+		}
+		return words.Words, nil
+	}
+
+	var words []string
+	decoder := json.NewDecoder(ctx.Request().Body)
+	for {
+		var word models.Word
+		err := decoder.Decode(&word)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, echo.NewHTTPError(400, "invalid NDJSON line: "+err.Error())
+		}
+		words = append(words, word.Word)
+	}
+	return words, nil
+}
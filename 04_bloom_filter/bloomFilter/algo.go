@@ -1,8 +1,6 @@
 package bloomFilter
 
 import (
-	"fmt"
-	"hash/fnv"
 	"sync"
 )
 
@@ -12,47 +10,87 @@ var (
 )
 
 type BloomFilter struct {
+	mu            sync.RWMutex
 	bits          []uint64
 	sizeInBits    int
+	hashCount     int
 	logicalColumn int
 	logicalRow    int
 }
 
 func InitBloomFilter(sizeInKB int, columnSize int) *BloomFilter {
 	once.Do(func() {
-		totalBits := sizeInKB * 8 * 1024 // size * 8 * 1024 bits
-		row := totalBits / columnSize
-		bloomFilter = &BloomFilter{
-			bits:          make([]uint64, row),
-			sizeInBits:    totalBits,
-			logicalColumn: columnSize,
-			logicalRow:    row,
-		}
+		bloomFilter = NewBloomFilter(sizeInKB, columnSize, 1)
 	})
 	return bloomFilter
 }
 
+// NewBloomFilter creates a standalone filter with hashCount hash functions
+func NewBloomFilter(sizeInKB int, columnSize int, hashCount int) *BloomFilter {
+	if hashCount < 1 {
+		hashCount = 1
+	}
+	totalBits := sizeInKB * 8 * 1024 // size * 8 * 1024 bits
+	row := totalBits / columnSize
+	return &BloomFilter{
+		bits:          make([]uint64, row),
+		sizeInBits:    totalBits,
+		hashCount:     hashCount,
+		logicalColumn: columnSize,
+		logicalRow:    row,
+	}
+}
+
 func GetBloomFilter() *BloomFilter {
 	return bloomFilter
 }
 
-// hash computes a single hash value
-func (bf *BloomFilter) hash(data []byte) uint64 {
-	h := fnv.New64()
-	_, _ = h.Write(data)
-	return h.Sum64()
+// Add inserts an element into the bloom filter
+func (bf *BloomFilter) Add(data []byte) (rowIdx int, colIdx int, err error) {
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	pos := positions(data, bf.sizeInBits, bf.hashCount)
+	rowIdx, colIdx = bf.setBit(pos[0])
+	for _, p := range pos[1:] {
+		bf.setBit(p)
+	}
+	return rowIdx, colIdx, nil
 }
 
-// Add inserts an element into the bloom filter
-func (bf *BloomFilter) Add(data []byte) (rowIdx int, colIdx int) {
-	// Perform modulo on uint64 to ensure index is always positive
-	index := bf.hash(data) % uint64(bf.sizeInBits)
-	return bf.setBit(int(index))
+func (bf *BloomFilter) Contains(data []byte) (isFound bool, rowIdx int, colIdx int, err error) {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	pos := positions(data, bf.sizeInBits, bf.hashCount)
+	isFound, rowIdx, colIdx = bf.getBit(pos[0])
+	for _, p := range pos[1:] {
+		if !isFound {
+			break
+		}
+		isFound, _, _ = bf.getBit(p)
+	}
+	return isFound, rowIdx, colIdx, nil
 }
 
-func (bf *BloomFilter) Contains(data []byte) (isFound bool, rowIdx int, colIdx int) {
-	index := bf.hash(data) % uint64(bf.sizeInBits)
-	return bf.getBit(int(index))
+// AddBatch inserts every element and reports the first bit of each
+func (bf *BloomFilter) AddBatch(items [][]byte) ([]Result, error) {
+	results := make([]Result, len(items))
+	for i, item := range items {
+		rowIdx, colIdx, _ := bf.Add(item)
+		results[i] = Result{IsFound: true, RowIdx: rowIdx, ColIdx: colIdx}
+	}
+	return results, nil
+}
+
+// ContainsBatch checks every element
+func (bf *BloomFilter) ContainsBatch(items [][]byte) ([]Result, error) {
+	results := make([]Result, len(items))
+	for i, item := range items {
+		isFound, rowIdx, colIdx, _ := bf.Contains(item)
+		results[i] = Result{IsFound: isFound, RowIdx: rowIdx, ColIdx: colIdx}
+	}
+	return results, nil
 }
 
 // Size returns the size of the bit array
@@ -61,18 +99,20 @@ func (bf *BloomFilter) Size() int {
 }
 
 // Clear resets all bits to zero
-func (bf *BloomFilter) Clear() {
+func (bf *BloomFilter) Clear() error {
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
 	for i := range bf.bits {
 		bf.bits[i] = 0
 	}
+	return nil
 }
 
 func (bf *BloomFilter) setBit(pos int) (rowIdx int, colIdx int) {
 	rowIndex := pos / bf.logicalColumn
 	colIndex := pos % bf.logicalColumn
 
-	fmt.Printf("Row: %d, Column: %d\n", rowIndex, colIndex)
-
 	bf.bits[rowIndex] |= (1 << colIndex)
 	return rowIndex, colIndex
 }
@@ -0,0 +1,67 @@
+package bloomFilter
+
+import "hash/fnv"
+
+// Filter is the membership contract shared by every bloom filter backend
+type Filter interface {
+	// Add inserts an element and reports the row/column of its first bit
+	Add(data []byte) (rowIdx int, colIdx int, err error)
+	// Contains reports whether all k bits of the element are set
+	Contains(data []byte) (isFound bool, rowIdx int, colIdx int, err error)
+	// AddBatch inserts many elements, one Result per element in order
+	AddBatch(items [][]byte) ([]Result, error)
+	// ContainsBatch checks many elements, one Result per element in order
+	ContainsBatch(items [][]byte) ([]Result, error)
+	// Size returns the number of bits in the filter
+	Size() int
+	// Clear resets all bits to zero
+	Clear() error
+}
+
+// Deleter is implemented by backends that can remove elements.
+// A plain bloom filter cannot, since bits are shared between elements.
+type Deleter interface {
+	Delete(data []byte) (bool, error)
+}
+
+// Result is the per-element outcome of a batch call
+type Result struct {
+	IsFound bool
+	RowIdx  int
+	ColIdx  int
+}
+
+var filter Filter = nil
+
+// UseFilter selects the backend returned by GetFilter and registers it
+// under DefaultFilterName
+func UseFilter(f Filter) {
+	filter = f
+	RegisterFilter(DefaultFilterName, f)
+}
+
+// GetFilter returns the backend selected at startup
+func GetFilter() Filter {
+	return filter
+}
+
+// positions returns the k bit positions of data using double hashing
+// (h1 + i*h2) % m. With k = 1 it is the plain fnv hash used before.
+func positions(data []byte, sizeInBits int, hashCount int) []int {
+	h := fnv.New64()
+	_, _ = h.Write(data)
+	h1 := h.Sum64()
+
+	h2 := uint64(0)
+	if hashCount > 1 {
+		ha := fnv.New64a()
+		_, _ = ha.Write(data)
+		h2 = ha.Sum64() | 1 // odd step so the k positions don't collapse
+	}
+
+	pos := make([]int, hashCount)
+	for i := 0; i < hashCount; i++ {
+		pos[i] = int((h1 + uint64(i)*h2) % uint64(sizeInBits))
+	}
+	return pos
+}
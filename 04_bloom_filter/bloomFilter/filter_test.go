@@ -0,0 +1,118 @@
+package bloomFilter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+// backends returns every Filter implementation with the same m and k. The
+// Redis one talks to an in-process miniredis, so no server is needed.
+func backends(t *testing.T) map[string]Filter {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	return map[string]Filter{
+		"memory": NewBloomFilter(1, 64, 3),
+		"redis":  NewRedisBloomFilter(client, "bloom:test", 1, 64, 3),
+	}
+}
+
+func TestFilterContract(t *testing.T) {
+	for name, f := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			if got, want := f.Size(), 1*8*1024; got != want {
+				t.Errorf("Size() = %d, want %d", got, want)
+			}
+
+			row, col, err := f.Add([]byte("apple"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			found, gotRow, gotCol, err := f.Contains([]byte("apple"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !found || gotRow != row || gotCol != col {
+				t.Errorf("Contains(apple) = %v at %d/%d, want true at %d/%d", found, gotRow, gotCol, row, col)
+			}
+			if found, _, _, _ := f.Contains([]byte("banana")); found {
+				t.Error("Contains(banana) = true before it was added")
+			}
+
+			added := [][]byte{[]byte("cherry"), []byte("date"), []byte("elder")}
+			addResults, err := f.AddBatch(added)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checked, err := f.ContainsBatch(append(added, []byte("fig")))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(addResults) != len(added) || len(checked) != len(added)+1 {
+				t.Fatalf("got %d and %d results for %d and %d items", len(addResults), len(checked), len(added), len(added)+1)
+			}
+			for i, r := range addResults {
+				if !checked[i].IsFound || checked[i].RowIdx != r.RowIdx || checked[i].ColIdx != r.ColIdx {
+					t.Errorf("ContainsBatch[%d] = %+v, want found at %d/%d", i, checked[i], r.RowIdx, r.ColIdx)
+				}
+			}
+			if checked[len(added)].IsFound {
+				t.Error("ContainsBatch reported fig before it was added")
+			}
+
+			if results, err := f.AddBatch(nil); err != nil || len(results) != 0 {
+				t.Errorf("AddBatch(nil) = %v, %v", results, err)
+			}
+
+			if err := f.Clear(); err != nil {
+				t.Fatal(err)
+			}
+			for _, word := range append(added, []byte("apple")) {
+				if found, _, _, _ := f.Contains(word); found {
+					t.Errorf("Contains(%s) = true after Clear", word)
+				}
+			}
+		})
+	}
+}
+
+// Both backends hash the same way, so a word lands on the same bits in each
+func TestFilterBackendsAgree(t *testing.T) {
+	filters := backends(t)
+	memory, redisFilter := filters["memory"], filters["redis"]
+
+	for i := 0; i < 200; i++ {
+		word := []byte(fmt.Sprintf("word-%d", i))
+		if i%2 == 0 {
+			memory.Add(word)
+			redisFilter.Add(word)
+		}
+		inMemory, memRow, memCol, err := memory.Contains(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inRedis, redisRow, redisCol, err := redisFilter.Contains(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inMemory != inRedis || memRow != redisRow || memCol != redisCol {
+			t.Errorf("%s: memory %v at %d/%d, redis %v at %d/%d", word, inMemory, memRow, memCol, inRedis, redisRow, redisCol)
+		}
+	}
+
+	snapshot, err := redisFilter.(Merger).Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	memorySnapshot, _ := memory.(Merger).Snapshot()
+	for i := range memorySnapshot.bits {
+		if snapshot.bits[i] != memorySnapshot.bits[i] {
+			t.Fatalf("snapshot row %d: redis %x, memory %x", i, snapshot.bits[i], memorySnapshot.bits[i])
+		}
+	}
+}
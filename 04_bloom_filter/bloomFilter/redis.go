@@ -0,0 +1,207 @@
+package bloomFilter
+
+import (
+	"github.com/go-redis/redis"
+)
+
+// RedisBloomFilter keeps the bit array in a Redis bitmap so every API pod
+// shares the same view of which words were added.
+type RedisBloomFilter struct {
+	client        redis.Cmdable
+	key           string
+	sizeInBits    int
+	hashCount     int
+	logicalColumn int
+}
+
+// NewRedisBloomFilter creates a filter of sizeInKB stored under key.
+// columnSize only affects the row/column reported back to callers.
+func NewRedisBloomFilter(client redis.Cmdable, key string, sizeInKB int, columnSize int, hashCount int) *RedisBloomFilter {
+	if hashCount < 1 {
+		hashCount = 1
+	}
+	return &RedisBloomFilter{
+		client:        client,
+		key:           key,
+		sizeInBits:    sizeInKB * 8 * 1024,
+		hashCount:     hashCount,
+		logicalColumn: columnSize,
+	}
+}
+
+// Add sets the k bits of data in a single pipeline round trip
+func (rbf *RedisBloomFilter) Add(data []byte) (rowIdx int, colIdx int, err error) {
+	pos := positions(data, rbf.sizeInBits, rbf.hashCount)
+
+	pipe := rbf.client.Pipeline()
+	for _, p := range pos {
+		pipe.SetBit(rbf.key, int64(p), 1)
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return 0, 0, err
+	}
+
+	rowIdx, colIdx = rbf.rowCol(pos[0])
+	return rowIdx, colIdx, nil
+}
+
+// Contains reads the k bits of data in a single pipeline round trip
+func (rbf *RedisBloomFilter) Contains(data []byte) (isFound bool, rowIdx int, colIdx int, err error) {
+	pos := positions(data, rbf.sizeInBits, rbf.hashCount)
+
+	pipe := rbf.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(pos))
+	for i, p := range pos {
+		cmds[i] = pipe.GetBit(rbf.key, int64(p))
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return false, 0, 0, err
+	}
+
+	isFound = true
+	for _, cmd := range cmds {
+		if cmd.Val() == 0 {
+			isFound = false
+			break
+		}
+	}
+
+	rowIdx, colIdx = rbf.rowCol(pos[0])
+	return isFound, rowIdx, colIdx, nil
+}
+
+// AddBatch sets the bits of every element in a single pipeline round trip
+func (rbf *RedisBloomFilter) AddBatch(items [][]byte) ([]Result, error) {
+	results := make([]Result, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	pipe := rbf.client.Pipeline()
+	for i, item := range items {
+		pos := positions(item, rbf.sizeInBits, rbf.hashCount)
+		for _, p := range pos {
+			pipe.SetBit(rbf.key, int64(p), 1)
+		}
+		rowIdx, colIdx := rbf.rowCol(pos[0])
+		results[i] = Result{IsFound: true, RowIdx: rowIdx, ColIdx: colIdx}
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// ContainsBatch reads the bits of every element in a single pipeline round trip
+func (rbf *RedisBloomFilter) ContainsBatch(items [][]byte) ([]Result, error) {
+	results := make([]Result, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	pipe := rbf.client.Pipeline()
+	cmds := make([][]*redis.IntCmd, len(items))
+	for i, item := range items {
+		pos := positions(item, rbf.sizeInBits, rbf.hashCount)
+		cmds[i] = make([]*redis.IntCmd, len(pos))
+		for j, p := range pos {
+			cmds[i][j] = pipe.GetBit(rbf.key, int64(p))
+		}
+		rowIdx, colIdx := rbf.rowCol(pos[0])
+		results[i] = Result{RowIdx: rowIdx, ColIdx: colIdx}
+	}
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		results[i].IsFound = true
+		for _, cmd := range cmds[i] {
+			if cmd.Val() == 0 {
+				results[i].IsFound = false
+				break
+			}
+		}
+	}
+	return results, nil
+}
+
+// Size returns the size of the bit array
+func (rbf *RedisBloomFilter) Size() int {
+	return rbf.sizeInBits
+}
+
+// Clear drops the bitmap key
+func (rbf *RedisBloomFilter) Clear() error {
+	return rbf.client.Del(rbf.key).Err()
+}
+
+func (rbf *RedisBloomFilter) rowCol(pos int) (rowIdx int, colIdx int) {
+	return pos / rbf.logicalColumn, pos % rbf.logicalColumn
+}
+
+// Union ORs other into the Redis bitmap with BITOP
+func (rbf *RedisBloomFilter) Union(other *BloomFilter) error {
+	return rbf.bitop("OR", other)
+}
+
+// Intersect ANDs other into the Redis bitmap with BITOP
+func (rbf *RedisBloomFilter) Intersect(other *BloomFilter) error {
+	return rbf.bitop("AND", other)
+}
+
+// Snapshot copies the Redis bitmap into an in-memory filter
+func (rbf *RedisBloomFilter) Snapshot() (*BloomFilter, error) {
+	raw, err := rbf.client.Get(rbf.key).Bytes()
+	if err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	bf := rbf.emptyCopy()
+	for pos := 0; pos < rbf.sizeInBits && pos/8 < len(raw); pos++ {
+		// Redis numbers bits from the most significant bit of each byte
+		if raw[pos/8]&(0x80>>(pos%8)) != 0 {
+			bf.setBit(pos)
+		}
+	}
+	return bf, nil
+}
+
+func (rbf *RedisBloomFilter) bitop(op string, other *BloomFilter) error {
+	if err := rbf.emptyCopy().Compatible(other); err != nil {
+		return err
+	}
+
+	raw := make([]byte, (rbf.sizeInBits+7)/8)
+	other.mu.RLock()
+	for pos := 0; pos < rbf.sizeInBits; pos++ {
+		if found, _, _ := other.getBit(pos); found {
+			raw[pos/8] |= 0x80 >> (pos % 8)
+		}
+	}
+	other.mu.RUnlock()
+
+	tmpKey := rbf.key + ":merge"
+	pipe := rbf.client.TxPipeline()
+	pipe.Set(tmpKey, raw, 0)
+	if op == "OR" {
+		pipe.BitOpOr(rbf.key, rbf.key, tmpKey)
+	} else {
+		pipe.BitOpAnd(rbf.key, rbf.key, tmpKey)
+	}
+	pipe.Del(tmpKey)
+	_, err := pipe.Exec()
+	return err
+}
+
+// emptyCopy returns an in-memory filter with the same m and k
+func (rbf *RedisBloomFilter) emptyCopy() *BloomFilter {
+	row := rbf.sizeInBits / rbf.logicalColumn
+	return &BloomFilter{
+		bits:          make([]uint64, row),
+		sizeInBits:    rbf.sizeInBits,
+		hashCount:     rbf.hashCount,
+		logicalColumn: rbf.logicalColumn,
+		logicalRow:    row,
+	}
+}
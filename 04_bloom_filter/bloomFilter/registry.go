@@ -0,0 +1,62 @@
+package bloomFilter
+
+import (
+	"errors"
+	"sync"
+)
+
+const (
+	// DefaultFilterName is the name the /words routes are registered under
+	DefaultFilterName = "words"
+
+	// Uploads can register new filters, so their number and total size are
+	// capped: 32 filters and 32 MB of bits
+	MaxNamedFilters = 32
+	MaxNamedBits    = 32 * 8 * 1024 * 1024
+)
+
+var (
+	namedFilters = map[string]Filter{}
+	namedBits    int
+	namedMu      sync.RWMutex
+
+	ErrRegistryFull = errors.New("too many named filters, or not enough room left for this one")
+)
+
+// RegisterFilter makes f reachable by name for the /filters routes
+func RegisterFilter(name string, f Filter) {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+	if old, ok := namedFilters[name]; ok {
+		namedBits -= old.Size()
+	}
+	namedFilters[name] = f
+	namedBits += f.Size()
+}
+
+// RegisterFilterIfAbsent registers f under name unless a filter already has
+// that name, and returns the filter that ends up registered. Two callers
+// racing for a new name both get the winner. It fails with ErrRegistryFull
+// when f would exceed MaxNamedFilters or MaxNamedBits.
+func RegisterFilterIfAbsent(name string, f Filter) (actual Filter, registered bool, err error) {
+	namedMu.Lock()
+	defer namedMu.Unlock()
+
+	if existing, ok := namedFilters[name]; ok {
+		return existing, false, nil
+	}
+	if len(namedFilters) >= MaxNamedFilters || namedBits+f.Size() > MaxNamedBits {
+		return nil, false, ErrRegistryFull
+	}
+	namedFilters[name] = f
+	namedBits += f.Size()
+	return f, true, nil
+}
+
+// GetNamedFilter looks up a filter registered with RegisterFilter
+func GetNamedFilter(name string) (Filter, bool) {
+	namedMu.RLock()
+	defer namedMu.RUnlock()
+	f, ok := namedFilters[name]
+	return f, ok
+}
@@ -0,0 +1,81 @@
+package bloomFilter
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// resetRegistry empties the named filters for the duration of a test
+func resetRegistry(t *testing.T) {
+	t.Helper()
+	namedMu.Lock()
+	saved, savedBits := namedFilters, namedBits
+	namedFilters, namedBits = map[string]Filter{}, 0
+	namedMu.Unlock()
+	t.Cleanup(func() {
+		namedMu.Lock()
+		namedFilters, namedBits = saved, savedBits
+		namedMu.Unlock()
+	})
+}
+
+func TestRegisterFilterIfAbsentRace(t *testing.T) {
+	resetRegistry(t)
+
+	const racers = 16
+	var wg sync.WaitGroup
+	winners := make(chan Filter, racers)
+	created := make(chan bool, racers)
+	for i := 0; i < racers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			actual, registered, err := RegisterFilterIfAbsent("workers", NewBloomFilter(1, 64, 3))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			winners <- actual
+			created <- registered
+		}()
+	}
+	wg.Wait()
+	close(winners)
+	close(created)
+
+	registered, _ := GetNamedFilter("workers")
+	for actual := range winners {
+		if actual != registered {
+			t.Fatal("a racer got a filter other than the registered one")
+		}
+	}
+	count := 0
+	for ok := range created {
+		if ok {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("%d racers registered the filter, want 1", count)
+	}
+}
+
+func TestRegisterFilterIfAbsentLimits(t *testing.T) {
+	resetRegistry(t)
+
+	for i := 0; i < MaxNamedFilters; i++ {
+		if _, _, err := RegisterFilterIfAbsent("f"+strconv.Itoa(i), NewBloomFilter(1, 64, 3)); err != nil {
+			t.Fatalf("filter %d: %v", i, err)
+		}
+	}
+	if _, _, err := RegisterFilterIfAbsent("one-too-many", NewBloomFilter(1, 64, 3)); !errors.Is(err, ErrRegistryFull) {
+		t.Errorf("filter %d: err = %v, want ErrRegistryFull", MaxNamedFilters, err)
+	}
+
+	resetRegistry(t)
+	if _, _, err := RegisterFilterIfAbsent("huge", NewBloomFilter(MaxNamedBits/8/1024+1, 64, 3)); !errors.Is(err, ErrRegistryFull) {
+		t.Errorf("oversized filter: err = %v, want ErrRegistryFull", err)
+	}
+}
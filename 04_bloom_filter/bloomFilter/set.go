@@ -0,0 +1,192 @@
+package bloomFilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// hashID identifies the hashing scheme in positions(); filters built with a
+// different scheme set different bits for the same word and cannot be merged
+const hashID uint8 = 1 // fnv64 + fnv64a double hashing
+
+// maxSerialRows caps uploads at 8 MB of bits (2^20 rows of 64 bits)
+const maxSerialRows = 1 << 20
+
+// maxHashCount bounds k on upload. Past a few dozen hash functions a filter
+// is only slower, and k = 0 would report every word as present.
+const maxHashCount = 64
+
+var (
+	serialMagic = [4]byte{'B', 'L', 'M', '1'}
+
+	ErrIncompatible = errors.New("bloom filters are incompatible")
+	ErrBadEncoding  = errors.New("invalid serialized bloom filter")
+)
+
+// Merger is implemented by backends that can combine with another filter.
+// Both sides must have the same m (size in bits), k (hash count) and hash.
+type Merger interface {
+	// Union ORs other into the receiver
+	Union(other *BloomFilter) error
+	// Intersect ANDs other into the receiver
+	Intersect(other *BloomFilter) error
+	// Snapshot returns an in-memory copy that can be serialized
+	Snapshot() (*BloomFilter, error)
+}
+
+var _ Merger = (*BloomFilter)(nil)
+var _ Merger = (*RedisBloomFilter)(nil)
+
+// serialHeader is written before the bit array
+type serialHeader struct {
+	Magic         [4]byte
+	HashID        uint8
+	HashCount     uint32
+	SizeInBits    uint64
+	LogicalColumn uint32
+}
+
+// Compatible reports why two filters cannot be combined, or nil if they can
+func (bf *BloomFilter) Compatible(other *BloomFilter) error {
+	switch {
+	case bf.sizeInBits != other.sizeInBits:
+		return fmt.Errorf("%w: m=%d vs m=%d", ErrIncompatible, bf.sizeInBits, other.sizeInBits)
+	case bf.hashCount != other.hashCount:
+		return fmt.Errorf("%w: k=%d vs k=%d", ErrIncompatible, bf.hashCount, other.hashCount)
+	case bf.logicalColumn != other.logicalColumn:
+		return fmt.Errorf("%w: %d vs %d columns", ErrIncompatible, bf.logicalColumn, other.logicalColumn)
+	}
+	return nil
+}
+
+// Union ORs other into bf, so bf answers true for anything added to either
+func (bf *BloomFilter) Union(other *BloomFilter) error {
+	if err := bf.Compatible(other); err != nil {
+		return err
+	}
+	if other == bf {
+		return nil
+	}
+	bits := other.copyBits()
+
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+	for i := range bf.bits {
+		bf.bits[i] |= bits[i]
+	}
+	return nil
+}
+
+// Intersect ANDs other into bf. The result may report more false positives
+// than a filter built from the intersection of the two sets directly.
+func (bf *BloomFilter) Intersect(other *BloomFilter) error {
+	if err := bf.Compatible(other); err != nil {
+		return err
+	}
+	if other == bf {
+		return nil
+	}
+	bits := other.copyBits()
+
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+	for i := range bf.bits {
+		bf.bits[i] &= bits[i]
+	}
+	return nil
+}
+
+// copyBits returns a copy of the bit array. Union and Intersect copy the
+// other filter first and never hold both locks, so a.Union(b) running next
+// to b.Union(a) cannot deadlock.
+func (bf *BloomFilter) copyBits() []uint64 {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+	return append([]uint64(nil), bf.bits...)
+}
+
+// Snapshot returns a copy of bf
+func (bf *BloomFilter) Snapshot() (*BloomFilter, error) {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	clone := &BloomFilter{
+		bits:          make([]uint64, len(bf.bits)),
+		sizeInBits:    bf.sizeInBits,
+		hashCount:     bf.hashCount,
+		logicalColumn: bf.logicalColumn,
+		logicalRow:    bf.logicalRow,
+	}
+	copy(clone.bits, bf.bits)
+	return clone, nil
+}
+
+// WriteTo serializes the header followed by the bits as little endian uint64s
+func (bf *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bf.mu.RLock()
+	defer bf.mu.RUnlock()
+
+	var buf bytes.Buffer
+	header := serialHeader{
+		Magic:         serialMagic,
+		HashID:        hashID,
+		HashCount:     uint32(bf.hashCount),
+		SizeInBits:    uint64(bf.sizeInBits),
+		LogicalColumn: uint32(bf.logicalColumn),
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, header); err != nil {
+		return 0, err
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, bf.bits); err != nil {
+		return 0, err
+	}
+	return buf.WriteTo(w)
+}
+
+// ReadBloomFilter parses a filter written by WriteTo
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	var header serialHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadEncoding, err)
+	}
+	if header.Magic != serialMagic {
+		return nil, fmt.Errorf("%w: bad magic", ErrBadEncoding)
+	}
+	if header.HashID != hashID {
+		return nil, fmt.Errorf("%w: hash %d vs %d", ErrIncompatible, header.HashID, hashID)
+	}
+	// The bits are stored as uint64 words, so only 64 bit columns make sense.
+	// Smaller ones would multiply the row count, and the allocation, by up to 64.
+	if header.LogicalColumn != 64 || header.SizeInBits == 0 || header.SizeInBits%64 != 0 || header.SizeInBits/64 > maxSerialRows {
+		return nil, fmt.Errorf("%w: bad dimensions", ErrBadEncoding)
+	}
+	if header.HashCount == 0 || header.HashCount > maxHashCount {
+		return nil, fmt.Errorf("%w: hash count %d, want 1 to %d", ErrBadEncoding, header.HashCount, maxHashCount)
+	}
+	row := header.SizeInBits / 64
+
+	// Read the body before allocating the bit array so a header that claims
+	// more rows than were sent costs only what was actually sent
+	body, err := io.ReadAll(io.LimitReader(r, int64(row)*8+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBadEncoding, err)
+	}
+	if uint64(len(body)) != row*8 {
+		return nil, fmt.Errorf("%w: body is %d bytes, header needs %d", ErrBadEncoding, len(body), row*8)
+	}
+
+	bf := &BloomFilter{
+		bits:          make([]uint64, row),
+		sizeInBits:    int(header.SizeInBits),
+		hashCount:     int(header.HashCount),
+		logicalColumn: int(header.LogicalColumn),
+		logicalRow:    int(row),
+	}
+	for i := range bf.bits {
+		bf.bits[i] = binary.LittleEndian.Uint64(body[i*8:])
+	}
+	return bf, nil
+}
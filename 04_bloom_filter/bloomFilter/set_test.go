@@ -0,0 +1,98 @@
+package bloomFilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestReadBloomFilterRoundTrip(t *testing.T) {
+	bf := NewBloomFilter(1, 64, 3)
+	bf.Add([]byte("hello"))
+
+	var buf bytes.Buffer
+	if _, err := bf.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadBloomFilter(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found, _, _, _ := got.Contains([]byte("hello")); !found {
+		t.Error("round trip lost an element")
+	}
+	if err := bf.Compatible(got); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReadBloomFilterRejectsBadHeaders(t *testing.T) {
+	header := func(sizeInBits uint64, logicalColumn uint32, hashCount uint32) *bytes.Buffer {
+		var buf bytes.Buffer
+		binary.Write(&buf, binary.LittleEndian, serialHeader{
+			Magic:         serialMagic,
+			HashID:        hashID,
+			HashCount:     hashCount,
+			SizeInBits:    sizeInBits,
+			LogicalColumn: logicalColumn,
+		})
+		return &buf
+	}
+
+	tests := []struct {
+		name          string
+		sizeInBits    uint64
+		logicalColumn uint32
+		hashCount     uint32
+		body          int
+	}{
+		{"one column", 1 << 32, 1, 3, 0},
+		{"too many rows", (maxSerialRows + 1) * 64, 64, 3, 0},
+		{"body shorter than header", 1024 * 64, 64, 3, 8},
+		{"body longer than header", 64, 64, 3, 16},
+		{"size not a multiple of 64", 100, 64, 3, 16},
+		{"no hash functions", 64, 64, 0, 8},
+		{"too many hash functions", 64, 64, 1 << 30, 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := header(tt.sizeInBits, tt.logicalColumn, tt.hashCount)
+			buf.Write(make([]byte, tt.body))
+			if _, err := ReadBloomFilter(buf); !errors.Is(err, ErrBadEncoding) {
+				t.Errorf("err = %v, want ErrBadEncoding", err)
+			}
+		})
+	}
+}
+
+func TestMergeLockOrder(t *testing.T) {
+	a, b := NewBloomFilter(1, 64, 3), NewBloomFilter(1, 64, 3)
+	a.Add([]byte("apple"))
+	b.Add([]byte("banana"))
+
+	if err := a.Union(a); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Intersect(a); err != nil {
+		t.Fatal(err)
+	}
+
+	// a.Union(b) next to b.Union(a) used to take the locks in opposite order
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(2)
+		go func() { defer wg.Done(); a.Union(b) }()
+		go func() { defer wg.Done(); b.Union(a) }()
+	}
+	wg.Wait()
+
+	for _, word := range []string{"apple", "banana"} {
+		for name, f := range map[string]*BloomFilter{"a": a, "b": b} {
+			if found, _, _, _ := f.Contains([]byte(word)); !found {
+				t.Errorf("%s lost %s", name, word)
+			}
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package cuckooFilter
+
+import (
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+
+	"github.com/AVVKavvk/bloom_filter/bloomFilter"
+)
+
+const (
+	bucketSize = 4   // fingerprints per bucket (4-way buckets)
+	maxKicks   = 500 // relocations before the filter is considered full
+
+	// DefaultFingerprintBits gives about 0.2% false positives with 4-way
+	// buckets (2*4/2^f), below a bloom filter with the same bits per item
+	DefaultFingerprintBits = 12
+	maxFingerprintBits     = 16
+)
+
+var (
+	cuckooFilter *CuckooFilter = nil
+	once         sync.Once
+
+	ErrFilterFull = errors.New("cuckoo filter is full")
+)
+
+// make sure the word service can use either structure
+var _ bloomFilter.Filter = (*CuckooFilter)(nil)
+var _ bloomFilter.Deleter = (*CuckooFilter)(nil)
+
+// CuckooFilter stores an f bit fingerprint of each element in one of two
+// candidate buckets. Unlike the bloom filter it supports deletion.
+type CuckooFilter struct {
+	mu         sync.Mutex
+	table      []uint64 // fingerprints packed f bits apiece, bucket by bucket
+	fpBits     uint
+	fpMask     uint64
+	numBuckets uint64 // always a power of two so i1 ^ hash(fp) stays in range
+	count      int
+
+	// victim holds the fingerprint that was kicked out last when an insert
+	// ran out of relocations, so nothing already added is ever lost
+	victim      uint16
+	victimIndex uint64
+	hasVictim   bool
+}
+
+func InitCuckooFilter(capacity int, fingerprintBits int) *CuckooFilter {
+	once.Do(func() {
+		cuckooFilter = NewCuckooFilter(capacity, fingerprintBits)
+	})
+	return cuckooFilter
+}
+
+func GetCuckooFilter() *CuckooFilter {
+	return cuckooFilter
+}
+
+// NewCuckooFilter creates a filter able to hold roughly capacity elements
+// with fingerprintBits per fingerprint. fingerprintBits <= 0 uses
+// DefaultFingerprintBits and anything above 16 is capped at 16.
+func NewCuckooFilter(capacity int, fingerprintBits int) *CuckooFilter {
+	if fingerprintBits <= 0 {
+		fingerprintBits = DefaultFingerprintBits
+	}
+	if fingerprintBits > maxFingerprintBits {
+		fingerprintBits = maxFingerprintBits
+	}
+	numBuckets := nextPowerOfTwo(uint64((capacity + bucketSize - 1) / bucketSize))
+	slotBits := numBuckets * bucketSize * uint64(fingerprintBits)
+	return &CuckooFilter{
+		table:      make([]uint64, (slotBits+63)/64),
+		fpBits:     uint(fingerprintBits),
+		fpMask:     1<<fingerprintBits - 1,
+		numBuckets: numBuckets,
+	}
+}
+
+// Add inserts an element. rowIdx is the bucket and colIdx the slot it landed in.
+// Every call takes a slot, even for an element that is already there, so each
+// Add is undone by exactly one Delete. The same element fits 2*bucketSize
+// times plus the stash before Add reports ErrFilterFull.
+func (cf *CuckooFilter) Add(data []byte) (rowIdx int, colIdx int, err error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	fp, i1 := cf.fingerprintAndIndex(data)
+	i2 := cf.altIndex(i1, fp)
+
+	if slot := cf.insert(i1, fp); slot >= 0 {
+		cf.count++
+		return int(i1), slot, nil
+	}
+	if slot := cf.insert(i2, fp); slot >= 0 {
+		cf.count++
+		return int(i2), slot, nil
+	}
+
+	// The stash holds one fingerprint. While it is taken, kicking could end
+	// with a second homeless one, so only elements with a free slot fit.
+	if cf.hasVictim {
+		return 0, 0, ErrFilterFull
+	}
+
+	// both buckets full: kick a random fingerprint to its alternate bucket
+	i := i1
+	if rand.Intn(2) == 1 {
+		i = i2
+	}
+	cf.count++
+	rowIdx, colIdx = int(i), rand.Intn(bucketSize)
+	slot := colIdx
+	for k := 0; k < maxKicks; k++ {
+		kicked := cf.get(i, slot)
+		cf.set(i, slot, fp)
+		fp = kicked
+
+		i = cf.altIndex(i, fp)
+		if s := cf.insert(i, fp); s >= 0 {
+			return rowIdx, colIdx, nil
+		}
+		slot = rand.Intn(bucketSize)
+	}
+
+	cf.victim, cf.victimIndex, cf.hasVictim = fp, i, true
+	return rowIdx, colIdx, nil
+}
+
+// Contains reports whether the fingerprint is in either candidate bucket
+func (cf *CuckooFilter) Contains(data []byte) (isFound bool, rowIdx int, colIdx int, err error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	fp, i1 := cf.fingerprintAndIndex(data)
+	i2 := cf.altIndex(i1, fp)
+
+	if found, rowIdx, colIdx := cf.lookup(fp, i1, i2); found {
+		return true, rowIdx, colIdx, nil
+	}
+	return false, int(i1), -1, nil
+}
+
+// lookup finds fp in bucket i1, bucket i2 or the stash. A stashed
+// fingerprint has no slot, so colIdx is -1 for it.
+func (cf *CuckooFilter) lookup(fp uint16, i1 uint64, i2 uint64) (found bool, rowIdx int, colIdx int) {
+	if slot := cf.indexOf(i1, fp); slot >= 0 {
+		return true, int(i1), slot
+	}
+	if slot := cf.indexOf(i2, fp); slot >= 0 {
+		return true, int(i2), slot
+	}
+	if cf.hasVictim && cf.victim == fp && (cf.victimIndex == i1 || cf.victimIndex == i2) {
+		return true, int(cf.victimIndex), -1
+	}
+	return false, 0, -1
+}
+
+// Delete removes one copy of the element. Only delete elements that were added,
+// otherwise a colliding fingerprint of another element may be removed.
+func (cf *CuckooFilter) Delete(data []byte) (bool, error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	fp, i1 := cf.fingerprintAndIndex(data)
+	i2 := cf.altIndex(i1, fp)
+
+	for _, i := range []uint64{i1, i2} {
+		if slot := cf.indexOf(i, fp); slot >= 0 {
+			cf.set(i, slot, 0)
+			cf.count--
+			cf.reinsertVictim()
+			return true, nil
+		}
+	}
+	if cf.hasVictim && cf.victim == fp && (cf.victimIndex == i1 || cf.victimIndex == i2) {
+		cf.hasVictim = false
+		cf.count--
+		return true, nil
+	}
+	return false, nil
+}
+
+func (cf *CuckooFilter) AddBatch(items [][]byte) ([]bloomFilter.Result, error) {
+	results := make([]bloomFilter.Result, len(items))
+	for i, item := range items {
+		rowIdx, colIdx, err := cf.Add(item)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = bloomFilter.Result{IsFound: true, RowIdx: rowIdx, ColIdx: colIdx}
+	}
+	return results, nil
+}
+
+func (cf *CuckooFilter) ContainsBatch(items [][]byte) ([]bloomFilter.Result, error) {
+	results := make([]bloomFilter.Result, len(items))
+	for i, item := range items {
+		isFound, rowIdx, colIdx, _ := cf.Contains(item)
+		results[i] = bloomFilter.Result{IsFound: isFound, RowIdx: rowIdx, ColIdx: colIdx}
+	}
+	return results, nil
+}
+
+// Size returns the number of bits used by the fingerprint table
+func (cf *CuckooFilter) Size() int {
+	return int(cf.numBuckets) * bucketSize * int(cf.fpBits)
+}
+
+// Count returns the number of elements currently stored
+func (cf *CuckooFilter) Count() int {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.count
+}
+
+// Clear empties every bucket
+func (cf *CuckooFilter) Clear() error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	for i := range cf.table {
+		cf.table[i] = 0
+	}
+	cf.count = 0
+	cf.hasVictim = false
+	return nil
+}
+
+// reinsertVictim retries the stashed fingerprint after a slot was freed
+func (cf *CuckooFilter) reinsertVictim() {
+	if !cf.hasVictim {
+		return
+	}
+	i1 := cf.victimIndex
+	i2 := cf.altIndex(i1, cf.victim)
+	for _, i := range []uint64{i1, i2} {
+		if cf.insert(i, cf.victim) >= 0 {
+			cf.hasVictim = false
+			return
+		}
+	}
+}
+
+// fingerprintAndIndex derives the fingerprint from the top f bits of the hash
+// and the primary bucket from the low bits. 0 marks an empty slot so it is never used.
+func (cf *CuckooFilter) fingerprintAndIndex(data []byte) (uint16, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	sum := h.Sum64()
+
+	fp := uint16(sum >> (64 - cf.fpBits))
+	if fp == 0 {
+		fp = 1
+	}
+	return fp, sum & (cf.numBuckets - 1)
+}
+
+// altIndex is its own inverse: altIndex(altIndex(i, fp), fp) == i
+func (cf *CuckooFilter) altIndex(i uint64, fp uint16) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte{byte(fp), byte(fp >> 8)})
+	return (i ^ h.Sum64()) & (cf.numBuckets - 1)
+}
+
+// insert puts fp in the first empty slot of bucket i and returns the slot,
+// or -1 if the bucket is full
+func (cf *CuckooFilter) insert(i uint64, fp uint16) int {
+	for slot := 0; slot < bucketSize; slot++ {
+		if cf.get(i, slot) == 0 {
+			cf.set(i, slot, fp)
+			return slot
+		}
+	}
+	return -1
+}
+
+func (cf *CuckooFilter) indexOf(i uint64, fp uint16) int {
+	for slot := 0; slot < bucketSize; slot++ {
+		if cf.get(i, slot) == fp {
+			return slot
+		}
+	}
+	return -1
+}
+
+// get reads the fingerprint in slot of bucket i. A fingerprint may straddle
+// two words of the table.
+func (cf *CuckooFilter) get(i uint64, slot int) uint16 {
+	off := (i*bucketSize + uint64(slot)) * uint64(cf.fpBits)
+	word, shift := off/64, off%64
+
+	v := cf.table[word] >> shift
+	if shift+uint64(cf.fpBits) > 64 {
+		v |= cf.table[word+1] << (64 - shift)
+	}
+	return uint16(v & cf.fpMask)
+}
+
+func (cf *CuckooFilter) set(i uint64, slot int, fp uint16) {
+	off := (i*bucketSize + uint64(slot)) * uint64(cf.fpBits)
+	word, shift := off/64, off%64
+
+	cf.table[word] = cf.table[word]&^(cf.fpMask<<shift) | uint64(fp)<<shift
+	if shift+uint64(cf.fpBits) > 64 {
+		cf.table[word+1] = cf.table[word+1]&^(cf.fpMask>>(64-shift)) | uint64(fp)>>(64-shift)
+	}
+}
+
+func nextPowerOfTwo(n uint64) uint64 {
+	p := uint64(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
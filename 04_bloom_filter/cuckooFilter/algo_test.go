@@ -0,0 +1,121 @@
+package cuckooFilter
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestFingerprintBits(t *testing.T) {
+	for _, tc := range []struct {
+		bits     int
+		wantBits int
+	}{
+		{0, DefaultFingerprintBits},
+		{12, 12},
+		{16, 16},
+		{32, 16},
+	} {
+		cf := NewCuckooFilter(1024, tc.bits)
+		if got, want := cf.Size(), 1024*tc.wantBits; got != want {
+			t.Errorf("bits=%d: Size() = %d, want %d", tc.bits, got, want)
+		}
+	}
+}
+
+// 12 bit fingerprints straddle table words, every slot must read back what
+// was written without touching its neighbours
+func TestPackedSlots(t *testing.T) {
+	for _, bits := range []int{7, 12, 13, 16} {
+		cf := NewCuckooFilter(64, bits)
+		want := func(i uint64, slot int) uint16 {
+			return uint16((i*bucketSize+uint64(slot))*2654435761) & uint16(cf.fpMask)
+		}
+		for i := uint64(0); i < cf.numBuckets; i++ {
+			for slot := 0; slot < bucketSize; slot++ {
+				cf.set(i, slot, want(i, slot))
+			}
+		}
+		for i := uint64(0); i < cf.numBuckets; i++ {
+			for slot := 0; slot < bucketSize; slot++ {
+				if got := cf.get(i, slot); got != want(i, slot) {
+					t.Fatalf("bits=%d bucket %d slot %d = %d, want %d", bits, i, slot, got, want(i, slot))
+				}
+			}
+		}
+	}
+}
+
+// Each Add of the same element takes a slot and each Delete frees one, until
+// its two buckets and the stash are full
+func TestAddSameKeyRepeatedly(t *testing.T) {
+	cf := NewCuckooFilter(64, 12)
+	word := []byte("apple")
+
+	fp, i1 := cf.fingerprintAndIndex(word)
+	fits := 2*bucketSize + 1 // both buckets and the stash
+	if cf.altIndex(i1, fp) == i1 {
+		fits = bucketSize + 1
+	}
+
+	for i := 0; i < fits; i++ {
+		if _, _, err := cf.Add(word); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	if _, _, err := cf.Add(word); !errors.Is(err, ErrFilterFull) {
+		t.Fatalf("add %d = %v, want ErrFilterFull", fits, err)
+	}
+	if cf.Count() != fits {
+		t.Errorf("Count() = %d, want %d", cf.Count(), fits)
+	}
+
+	for i := 0; i < fits; i++ {
+		if found, _, _, _ := cf.Contains(word); !found {
+			t.Fatalf("apple not found with %d copies left", fits-i)
+		}
+		if deleted, _ := cf.Delete(word); !deleted {
+			t.Fatalf("delete %d = false", i)
+		}
+	}
+	if deleted, _ := cf.Delete(word); deleted {
+		t.Error("deleted more copies than were added")
+	}
+	if found, _, _, _ := cf.Contains(word); found {
+		t.Error("apple still found after every copy was deleted")
+	}
+	if cf.Count() != 0 {
+		t.Errorf("Count() = %d, want 0", cf.Count())
+	}
+}
+
+// Once a fingerprint is stashed, elements that still have a free slot in
+// one of their buckets are added as before
+func TestAddAfterStash(t *testing.T) {
+	cf := NewCuckooFilter(64, 16)
+	added := 0
+	for ; !cf.hasVictim; added++ {
+		if _, _, err := cf.Add(key("k", added)); err != nil {
+			t.Fatalf("add %d before the stash filled: %v", added, err)
+		}
+	}
+
+	after := 0
+	for i := 0; i < 1000; i++ {
+		_, _, err := cf.Add(key("more", i))
+		if err != nil && !errors.Is(err, ErrFilterFull) {
+			t.Fatal(err)
+		}
+		if err == nil {
+			after++
+		}
+	}
+	if after == 0 && cf.Count() < 64 {
+		t.Errorf("no add succeeded after the stash filled with %d of 64 slots used", cf.Count())
+	}
+
+	for i := 0; i < added; i++ {
+		if found, _, _, _ := cf.Contains(key("k", i)); !found {
+			t.Errorf("k:%d lost after the stash filled", i)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package cuckooFilter
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/AVVKavvk/bloom_filter/bloomFilter"
+)
+
+// Both structures are compared at the same memory: 4096 buckets of 4 slots
+// of f bits next to a bloom filter of 4096*4*f bits with its best k.
+//
+//	go test ./cuckooFilter -run '^$' -bench .
+const (
+	benchSlots = 4096 * bucketSize
+	benchItems = benchSlots * 9 / 10 // 90% load
+	benchProbe = 100000              // absent keys used to measure false positives
+)
+
+var candidates = []struct {
+	name string
+	new  func() bloomFilter.Filter
+}{
+	{"bloom/24KB/k=9", func() bloomFilter.Filter { return bloomFilter.NewBloomFilter(24, 64, 9) }},
+	{"cuckoo/f=12", func() bloomFilter.Filter { return NewCuckooFilter(benchSlots, 12) }},
+	{"bloom/32KB/k=11", func() bloomFilter.Filter { return bloomFilter.NewBloomFilter(32, 64, 11) }},
+	{"cuckoo/f=16", func() bloomFilter.Filter { return NewCuckooFilter(benchSlots, 16) }},
+}
+
+func BenchmarkAdd(b *testing.B) {
+	for _, c := range candidates {
+		b.Run(c.name, func(b *testing.B) {
+			f := c.new()
+			for i := 0; i < b.N; i++ {
+				// restart once full so the cuckoo filter never reports ErrFilterFull
+				if i%benchItems == 0 {
+					_ = f.Clear()
+				}
+				if _, _, err := f.Add(key("k", i%benchItems)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkContains also reports the measured false positive rate and the
+// bits spent per stored item
+func BenchmarkContains(b *testing.B) {
+	for _, c := range candidates {
+		b.Run(c.name, func(b *testing.B) {
+			f := c.new()
+			for i := 0; i < benchItems; i++ {
+				if _, _, err := f.Add(key("k", i)); err != nil {
+					b.Fatalf("filled up after %d inserts: %v", i, err)
+				}
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, _, _, _ = f.Contains(key("k", i%benchItems))
+			}
+			b.StopTimer()
+
+			falsePositives := 0
+			for i := 0; i < benchProbe; i++ {
+				if found, _, _, _ := f.Contains(key("absent", i)); found {
+					falsePositives++
+				}
+			}
+			b.ReportMetric(100*float64(falsePositives)/benchProbe, "fp%")
+			b.ReportMetric(float64(f.Size())/benchItems, "bits/item")
+		})
+	}
+}
+
+func key(prefix string, i int) []byte {
+	return []byte(prefix + ":" + strconv.Itoa(i))
+}
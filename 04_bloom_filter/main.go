@@ -1,8 +1,12 @@
 package main
 
 import (
+	"os"
+
 	"github.com/AVVKavvk/bloom_filter/api"
 	"github.com/AVVKavvk/bloom_filter/bloomFilter"
+	"github.com/AVVKavvk/bloom_filter/cuckooFilter"
+	"github.com/AVVKavvk/bloom_filter/redisClient"
 
 	_ "github.com/AVVKavvk/bloom_filter/docs"
 	"github.com/labstack/echo/v4"
@@ -17,16 +21,43 @@ import (
 func main() {
 	AlgoDryRun(1)
 
-	// Init bloomFiler with 1 Kb size and 64 columns
-	bloomFilter.InitBloomFilter(1, 64)
+	// BLOOM_BACKEND=redis shares one bitmap between every API pod,
+	// BLOOM_BACKEND=cuckoo swaps in a cuckoo filter that supports deletes,
+	// anything else keeps the per-process in-memory filter
+	switch os.Getenv("BLOOM_BACKEND") {
+	case "cuckoo":
+		// 512 slots of 16 bit fingerprints, same memory as the default bloom filter
+		bloomFilter.UseFilter(cuckooFilter.InitCuckooFilter(512, 16))
+	case "redis":
+		client := redisClient.InitRedisClient(getEnv("REDIS_ADDR", "localhost:6379"), getEnv("REDIS_PASSWORD", "mypass"))
+		// 1 Kb bitmap, 64 columns, 3 hash functions
+		bloomFilter.UseFilter(bloomFilter.NewRedisBloomFilter(client, "bloom:words", 1, 64, 3))
+	default:
+		// Init bloomFiler with 1 Kb size and 64 columns
+		bloomFilter.UseFilter(bloomFilter.InitBloomFilter(1, 64))
+	}
 
 	e := echo.New()
 
 	e.POST("/words", api.AddWord)
 	e.POST("/words/check", api.CheckWeatherWordIsExist)
+	e.DELETE("/words", api.DeleteWord)
+	e.POST("/words/batch", api.AddWords)
+	e.POST("/words/check/batch", api.CheckWords)
+	e.POST("/words/import", api.ImportWords)
+
+	e.GET("/filters/:name/export", api.ExportFilter)
+	e.POST("/filters/:name/merge", api.MergeFilter)
 
 	// Route to serve the Swagger UI
 	e.GET("/docs/*", echoSwagger.WrapHandler)
 
 	e.Logger.Fatal(e.Start(":8080"))
 }
+
+func getEnv(key string, fallback string) string {
+	if val, ok := os.LookupEnv(key); ok {
+		return val
+	}
+	return fallback
+}
@@ -12,3 +12,29 @@ type ResponseWordProbability struct {
 	RowIdx  int  `json:"rowIdx"`
 	ColIdx  int  `json:"colIdx"`
 }
+
+type Words struct {
+	Words []string `json:"words"`
+}
+type WordResult struct {
+	Word    string `json:"word"`
+	IsFound bool   `json:"isFound"`
+	RowIdx  int    `json:"rowIdx"`
+	ColIdx  int    `json:"colIdx"`
+}
+type ResponseBatch struct {
+	Results []WordResult `json:"results"`
+}
+type ResponseImport struct {
+	Imported int `json:"imported"`
+}
+type ResponseDeleteWord struct {
+	Deleted bool `json:"deleted"`
+}
+
+type ResponseMerge struct {
+	Name       string `json:"name"`
+	Op         string `json:"op"`
+	Created    bool   `json:"created"`
+	SizeInBits int    `json:"sizeInBits"`
+}
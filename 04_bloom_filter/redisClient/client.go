@@ -0,0 +1,35 @@
+package redisClient
+
+import (
+	"log"
+	"sync"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	rc   *redis.Client = nil
+	once sync.Once
+)
+
+func GetRedisClient() *redis.Client {
+	return rc
+}
+
+// InitRedisClient connects once; it is only called when the redis backend is selected
+func InitRedisClient(addr string, password string) *redis.Client {
+	once.Do(func() {
+		rc = redis.NewClient(&redis.Options{
+			Addr:     addr,
+			Password: password,
+			DB:       0, // use default DB
+		})
+		_, err := rc.Ping().Result()
+		if err != nil {
+			log.Fatalf("Could not connect to Redis: %v", err)
+		}
+
+		log.Println("redis client successfully connected")
+	})
+	return rc
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"errors"
+	"io"
+
+	"github.com/AVVKavvk/bloom_filter/bloomFilter"
+	"github.com/AVVKavvk/bloom_filter/models"
+)
+
+var (
+	ErrFilterNotFound    = errors.New("no filter registered with that name")
+	ErrMergeNotSupported = errors.New("the filter does not support union/intersection")
+	ErrUnknownMergeOp    = errors.New("op must be union or intersect")
+)
+
+// MergeFilterService reads a serialized filter from r and combines it into the
+// named filter. A union into a name that does not exist yet registers the
+// upload, as long as the registry has room for it.
+func MergeFilterService(name string, op string, r io.Reader) (*models.ResponseMerge, error) {
+	if op != "union" && op != "intersect" {
+		return nil, ErrUnknownMergeOp
+	}
+
+	uploaded, err := bloomFilter.ReadBloomFilter(r)
+	if err != nil {
+		return nil, err
+	}
+
+	target, ok := bloomFilter.GetNamedFilter(name)
+	if !ok {
+		if op != "union" {
+			return nil, ErrFilterNotFound
+		}
+		actual, created, err := bloomFilter.RegisterFilterIfAbsent(name, uploaded)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			return &models.ResponseMerge{Name: name, Op: op, Created: true, SizeInBits: uploaded.Size()}, nil
+		}
+		// another upload created it first, union into that one
+		target = actual
+	}
+
+	merger, ok := target.(bloomFilter.Merger)
+	if !ok {
+		return nil, ErrMergeNotSupported
+	}
+
+	if op == "union" {
+		err = merger.Union(uploaded)
+	} else {
+		err = merger.Intersect(uploaded)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &models.ResponseMerge{Name: name, Op: op, SizeInBits: target.Size()}, nil
+}
+
+// ExportFilterService writes the named filter in the format MergeFilterService reads
+func ExportFilterService(name string, w io.Writer) error {
+	target, ok := bloomFilter.GetNamedFilter(name)
+	if !ok {
+		return ErrFilterNotFound
+	}
+	merger, ok := target.(bloomFilter.Merger)
+	if !ok {
+		return ErrMergeNotSupported
+	}
+
+	snapshot, err := merger.Snapshot()
+	if err != nil {
+		return err
+	}
+	_, err = snapshot.WriteTo(w)
+	return err
+}
@@ -1,13 +1,21 @@
 package service
 
 import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+
 	"github.com/AVVKavvk/bloom_filter/bloomFilter"
 	"github.com/AVVKavvk/bloom_filter/models"
 )
 
 func AddWordService(word *models.Word) (*models.ResponseAddWord, error) {
-	blf := bloomFilter.GetBloomFilter()
-	rowInd, colInd := blf.Add([]byte(word.Word))
+	blf := bloomFilter.GetFilter()
+	rowInd, colInd, err := blf.Add([]byte(word.Word))
+	if err != nil {
+		return nil, err
+	}
 	return &models.ResponseAddWord{
 		RowIdx: rowInd,
 		ColIdx: colInd,
@@ -15,8 +23,11 @@ func AddWordService(word *models.Word) (*models.ResponseAddWord, error) {
 }
 
 func CheckWeatherWordIsExistService(word *models.Word) (*models.ResponseWordProbability, error) {
-	blf := bloomFilter.GetBloomFilter()
-	isFound, rawInd, colInd := blf.Contains([]byte(word.Word))
+	blf := bloomFilter.GetFilter()
+	isFound, rawInd, colInd, err := blf.Contains([]byte(word.Word))
+	if err != nil {
+		return nil, err
+	}
 
 	return &models.ResponseWordProbability{
 		IsFound: isFound,
@@ -24,3 +35,102 @@ func CheckWeatherWordIsExistService(word *models.Word) (*models.ResponseWordProb
 		ColIdx:  colInd,
 	}, nil
 }
+
+// ErrDeleteNotSupported is returned when the configured backend cannot remove words
+var ErrDeleteNotSupported = errors.New("the configured filter does not support deletes, use BLOOM_BACKEND=cuckoo")
+
+func DeleteWordService(word *models.Word) (*models.ResponseDeleteWord, error) {
+	deleter, ok := bloomFilter.GetFilter().(bloomFilter.Deleter)
+	if !ok {
+		return nil, ErrDeleteNotSupported
+	}
+	deleted, err := deleter.Delete([]byte(word.Word))
+	if err != nil {
+		return nil, err
+	}
+	return &models.ResponseDeleteWord{Deleted: deleted}, nil
+}
+
+// importChunkSize is how many words the bulk import hands to the filter at once
+const importChunkSize = 1000
+
+func AddWordsService(words []string) (*models.ResponseBatch, error) {
+	blf := bloomFilter.GetFilter()
+	results, err := blf.AddBatch(toBytes(words))
+	if err != nil {
+		return nil, err
+	}
+	return toResponseBatch(words, results), nil
+}
+
+func CheckWordsService(words []string) (*models.ResponseBatch, error) {
+	blf := bloomFilter.GetFilter()
+	results, err := blf.ContainsBatch(toBytes(words))
+	if err != nil {
+		return nil, err
+	}
+	return toResponseBatch(words, results), nil
+}
+
+// ImportWordsService streams one word per line from r into the filter
+func ImportWordsService(r io.Reader) (*models.ResponseImport, error) {
+	blf := bloomFilter.GetFilter()
+
+	scanner := bufio.NewScanner(r)
+	chunk := make([][]byte, 0, importChunkSize)
+	imported := 0
+
+	flush := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if _, err := blf.AddBatch(chunk); err != nil {
+			return err
+		}
+		imported += len(chunk)
+		chunk = chunk[:0]
+		return nil
+	}
+
+	for scanner.Scan() {
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" {
+			continue
+		}
+		chunk = append(chunk, []byte(word))
+		if len(chunk) == importChunkSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return &models.ResponseImport{Imported: imported}, nil
+}
+
+func toBytes(words []string) [][]byte {
+	items := make([][]byte, len(words))
+	for i, w := range words {
+		items[i] = []byte(w)
+	}
+	return items
+}
+
+func toResponseBatch(words []string, results []bloomFilter.Result) *models.ResponseBatch {
+	out := make([]models.WordResult, len(results))
+	for i, r := range results {
+		out[i] = models.WordResult{
+			Word:    words[i],
+			IsFound: r.IsFound,
+			RowIdx:  r.RowIdx,
+			ColIdx:  r.ColIdx,
+		}
+	}
+	return &models.ResponseBatch{Results: out}
+}
@@ -3,177 +3,322 @@ package algo
 import (
 	"sort"
 	"sync"
+	"sync/atomic"
+
+	"github.com/AVVKavvk/consistent-hashing/models"
 )
 
+// DefaultVirtualNodes is how many points a weight 1 node gets on the ring
+const DefaultVirtualNodes = 100
+
+// ringSize is the size of the crc32 hash space
+const ringSize = 1 << 32
+
 var (
 	hashRing *HashRing
 	once     sync.Once
-	mu       sync.RWMutex
 )
 
+// HashRing keeps the membership as an immutable *Ring behind an atomic
+// pointer: lookups load it without locking, AddNode/DeleteNode copy it,
+// change the copy and swap it in. The per-key bookkeeping changes on every
+// write and is guarded by mu instead.
 type HashRing struct {
-	nodes   []int            // Sorted list of virtual node hashes
-	nodeMap map[int]string   // Maps virtual node hash to physical node name
-	hashes  map[string][]int // Maps physical node name to virtual node hash
-	userIds map[string][]string
+	ring       atomic.Pointer[Ring]
+	membership sync.Mutex // serializes copy-on-write membership changes
+
+	mu      sync.RWMutex
+	hashes  map[string][]int    // Maps physical node name to the key hashes stored on it
+	userIds map[string][]string // Maps physical node name to the user ids stored on it
+	joining map[string]bool     // Physical nodes still receiving keys from their successors
+
+	// bounded loads, see bounded.go
+	boundedLoad bool
+	epsilon     float64
+	spills      sync.Map       // Key hash to the node it spilled to, read lock-free by lookups
+	spilled     int            // Number of entries in spills
+	primaries   map[int]string // Key hash to its current owner, for load accounting
+	loads       map[string]int // Physical node name to the number of keys it owns
 }
 
 func InitHashRing() *HashRing {
 	once.Do(func() {
-		hashRing = &HashRing{
-			nodes:   make([]int, 0),
-			nodeMap: make(map[int]string),
-			hashes:  make(map[string][]int),
-			userIds: make(map[string][]string),
-		}
+		hashRing = NewHashRing()
 	})
 	return hashRing
 }
 
+// NewHashRing returns an empty ring. The service uses the one from
+// InitHashRing; tests and cmd/cluster run one per in-process instance.
+func NewHashRing() *HashRing {
+	hr := &HashRing{
+		hashes:  make(map[string][]int),
+		userIds: make(map[string][]string),
+		joining: make(map[string]bool),
+
+		epsilon:   DefaultEpsilon,
+		primaries: make(map[int]string),
+		loads:     make(map[string]int),
+	}
+	hr.ring.Store(emptyRing())
+	return hr
+}
+
 func GetHashRing() *HashRing {
 	return hashRing
 }
 
-// AddNode adds a physical server to the ring
-func (hr *HashRing) AddNode(nodeName string) {
-
-	// Create a unique key for each virtual node
-	hash := GetHashForNode(nodeName)
-	mu.Lock()
-	hr.nodes = append(hr.nodes, hash)
-	hr.nodeMap[hash] = nodeName
-	mu.Unlock()
+// Snapshot returns the current membership. It never changes, so a caller can
+// make several lookups against the same ring version.
+func (hr *HashRing) Snapshot() *Ring {
+	return hr.ring.Load()
+}
 
-	sort.Ints(hr.nodes)
+// Version returns the current ring version
+func (hr *HashRing) Version() uint64 {
+	return hr.ring.Load().Version
 }
 
-// GetOwner returns the server responsible for the given key
-func (hr *HashRing) GetOwner(key string) (serverName string, hashOfKey int) {
-	if len(hr.nodes) == 0 {
-		return "", 0
+// AddNode adds a physical server to the ring with virtualNodes*weight points
+// and returns the new ring version. virtualNodes <= 0 uses
+// DefaultVirtualNodes and weight <= 0 means 1.
+func (hr *HashRing) AddNode(nodeName string, virtualNodes int, weight int) uint64 {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
 	}
-	hash := GetHashForKeyForGettingOwner(key)
-	// Binary search to find the first node hash >= key hash
-	idx := sort.Search(len(hr.nodes), func(i int) bool {
-		return hr.nodes[i] >= hash
-	})
-	// If we've reached the end of the ring, wrap around to the first node
-	if idx == len(hr.nodes) {
-		idx = 0
+	if weight <= 0 {
+		weight = 1
 	}
-	return hr.nodeMap[hr.nodes[idx]], hash
-}
 
-func (hr *HashRing) AddUserIdToNode(nodeName string, userId string) {
+	hr.membership.Lock()
+	defer hr.membership.Unlock()
 
-	mu.Lock()
-	hr.userIds[nodeName] = append(hr.userIds[nodeName], userId)
-	mu.Unlock()
+	current := hr.ring.Load()
+	if current.Has(nodeName) {
+		return current.Version
+	}
+
+	next := current.clone()
+	vnodes := make([]int, 0, virtualNodes*weight)
+	for i := 0; i < virtualNodes*weight; i++ {
+		// Create a unique key for each virtual node
+		hash := GetHashForVirtualNode(nodeName, i)
+		// Two virtual nodes landing on the same point is rare with crc32,
+		// the first one keeps the point
+		if _, taken := next.nodeMap[hash]; taken {
+			continue
+		}
+		next.nodes = append(next.nodes, hash)
+		next.nodeMap[hash] = nodeName
+		vnodes = append(vnodes, hash)
+	}
+	sort.Ints(next.nodes)
+	sort.Ints(vnodes)
+	next.vnodes[nodeName] = vnodes
+	next.weights[nodeName] = weight
+	next.perUnit[nodeName] = virtualNodes
+
+	hr.ring.Store(next)
+	return next.Version
 }
-func (hr *HashRing) DeleteNode(nodeName string) {
-	// Remove from nodeMap and track which hashes to remove
-	hashesToRemove := make(map[int]bool)
 
-	hash := GetHashForNode(nodeName)
-	mu.Lock()
-	delete(hr.nodeMap, hash)
-	mu.Unlock()
-	hashesToRemove[hash] = true
+// DeleteNode removes a physical server and its key bookkeeping and returns
+// the new ring version
+func (hr *HashRing) DeleteNode(nodeName string) uint64 {
+	hr.membership.Lock()
+	defer hr.membership.Unlock()
 
-	mu.Lock()
-	delete(hr.hashes, nodeName)
-	mu.Unlock()
+	current := hr.ring.Load()
+	if !current.Has(nodeName) {
+		return current.Version
+	}
 
-	mu.Lock()
-	delete(hr.userIds, nodeName)
-	mu.Unlock()
+	next := current.clone()
+	// Remove from nodeMap and track which hashes to remove
+	hashesToRemove := make(map[int]bool)
+	for _, hash := range next.vnodes[nodeName] {
+		delete(next.nodeMap, hash)
+		hashesToRemove[hash] = true
+	}
+	delete(next.vnodes, nodeName)
+	delete(next.weights, nodeName)
+	delete(next.perUnit, nodeName)
 
-	// Filter the nodes slice to remove the virtual nodes
-	newNodes := make([]int, 0, len(hr.nodes))
-	for _, v := range hr.nodes {
+	// Filter the nodes slice to remove the virtual nodes, it stays sorted
+	newNodes := make([]int, 0, len(next.nodes))
+	for _, v := range next.nodes {
 		if !hashesToRemove[v] {
 			newNodes = append(newNodes, v)
 		}
 	}
-	//OPTIONAL: But Sort the newNodes slice to ensure the ring is sorted
-	sort.Ints(newNodes)
+	next.nodes = newNodes
+
+	hr.mu.Lock()
+	hr.spills.Range(func(hash, spill any) bool {
+		if spill == nodeName {
+			hr.spills.Delete(hash)
+			hr.spilled--
+		}
+		return true
+	})
+	// Its keys are unowned until the rebalance assigns them again
+	for hash, owner := range hr.primaries {
+		if owner == nodeName {
+			delete(hr.primaries, hash)
+		}
+	}
+	delete(hr.loads, nodeName)
+	delete(hr.hashes, nodeName)
+	delete(hr.userIds, nodeName)
+	hr.ring.Store(next)
+	hr.mu.Unlock()
+
+	return next.Version
+}
+
+// GetOwner returns the server responsible for the given key
+func (hr *HashRing) GetOwner(key string) (serverName string, hashOfKey int) {
+	hash := GetHashForKeyForGettingOwner(key)
+	return hr.GetOwnerForHash(hash), hash
+}
+
+// GetOwnerForHash returns the server owning the first virtual node >= hash,
+// or the node the key spilled to under bounded loads
+func (hr *HashRing) GetOwnerForHash(hash int) (serverName string) {
+	return hr.primaryForHash(hr.ring.Load(), hash)
+}
+
+// GetPreferenceList returns the first n distinct physical servers clockwise
+// from hash and the ring version they were computed on. The first one is the
+// owner, the rest hold replicas.
+func (hr *HashRing) GetPreferenceList(hash int, n int) ([]string, uint64) {
+	r := hr.ring.Load()
+	// a key that spilled keeps its spill target as owner, replicas follow
+	// clockwise from the natural owner as usual
+	spill, _ := hr.spills.Load(hash)
+	first, _ := spill.(string)
+	return r.walkClockwise(hash, n, first), r.Version
+}
+
+func (hr *HashRing) AddUserIdToNode(nodeName string, userId string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
 
-	hr.nodes = newNodes
+	for _, id := range hr.userIds[nodeName] {
+		if id == userId {
+			return
+		}
+	}
+	hr.userIds[nodeName] = append(hr.userIds[nodeName], userId)
 }
 
+// FindTheNextNodeForNode returns the first other physical server clockwise
+// from the node's first virtual node, or "" if it is the only server
 func (hr *HashRing) FindTheNextNodeForNode(nodeName string) (serverName string) {
+	return hr.FindHandoffNode(nodeName, func(string) bool { return true })
+}
+
+// FindHandoffNode returns the first other physical server clockwise from the
+// node's first virtual node for which alive reports true, or "" if there is
+// none. Instances with the same ring and the same view of who is alive pick
+// the same server, so one of them can move the node's keys without the
+// others talking to it.
+func (hr *HashRing) FindHandoffNode(nodeName string, alive func(string) bool) (serverName string) {
+	r := hr.ring.Load()
+
 	hash := GetHashForNode(nodeName)
-	idx := sort.Search(len(hr.nodes), func(i int) bool {
+	idx := sort.Search(len(r.nodes), func(i int) bool {
 		// it should be > not != because it will return the same server again
-		return hr.nodes[i] > hash
+		return r.nodes[i] > hash
 	})
-	if idx == len(hr.nodes) {
-		idx = 0
+	for step := 0; step < len(r.nodes); step++ {
+		name := r.nodeMap[r.nodes[(idx+step)%len(r.nodes)]]
+		if name != nodeName && alive(name) {
+			return name
+		}
 	}
-	return hr.nodeMap[hr.nodes[idx]]
+	return ""
 }
 
 func (hr *HashRing) GetNodeInfo(nodeName string) map[string]interface{} {
-	mu.RLock()
-	defer mu.RUnlock()
+	r := hr.ring.Load()
+
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
 	return map[string]interface{}{
-		"nodeName":   nodeName,
-		"serverHash": GetHashForNode(nodeName),
-		"hashes":     hr.hashes[nodeName],
-		"userIds":    hr.userIds[nodeName],
+		"nodeName":    nodeName,
+		"serverHash":  GetHashForNode(nodeName),
+		"weight":      r.weights[nodeName],
+		"vnodes":      len(r.vnodes[nodeName]),
+		"hashes":      append([]int(nil), hr.hashes[nodeName]...),
+		"userIds":     append([]string(nil), hr.userIds[nodeName]...),
+		"ringVersion": r.Version,
 	}
 }
 
 func (hr *HashRing) GetAllNodeInfo() []map[string]interface{} {
-	mu.RLock()
-	defer mu.RUnlock()
+	r := hr.ring.Load()
 
 	nodeInfo := make([]map[string]interface{}, 0)
-	nodeMap := hr.nodeMap
-
 	nodeInfo = append(nodeInfo, map[string]interface{}{
-		"nodes": nodeMap,
+		"nodes":       r.nodeMap,
+		"ringVersion": r.Version,
 	})
 	return nodeInfo
 }
-func (hr *HashRing) AddHashToNode(nodeName string, hash int) {
-	mu.Lock()
-	hr.hashes[nodeName] = append(hr.hashes[nodeName], hash)
-	sort.Ints(hr.hashes[nodeName])
-	mu.Unlock()
-}
 
-func (hr *HashRing) GetHashesForNode(nodeName string) []int {
-	mu.RLock()
-	defer mu.RUnlock()
-	return hr.hashes[nodeName]
-}
+// GetDistribution reports, for every physical node, the share of the hash
+// space its virtual nodes own next to the share its weight asks for
+func (hr *HashRing) GetDistribution() models.Distribution {
+	r := hr.ring.Load()
 
-func (hr *HashRing) GetAllTheHashLessThanOrEqualToThisHash(nodeName string, hash int) []int {
+	owned := make(map[string]int, len(r.weights))
+	for i, hash := range r.nodes {
+		// a virtual node owns the arc (previous virtual node, hash]
+		prev := r.nodes[(i-1+len(r.nodes))%len(r.nodes)]
+		arc := hash - prev
+		if arc <= 0 {
+			arc += ringSize
+		}
+		owned[r.nodeMap[hash]] += arc
+	}
 
-	mu.RLock()
-	defer mu.RUnlock()
-	// Binary search to find the first node hash >= key hash
-	idx := sort.Search(len(hr.nodes), func(i int) bool {
-		return hr.nodes[i] >= hash
-	})
-	// If we've reached the end of the ring, wrap around to the first node
-	if idx == len(hr.nodes) {
-		idx = 0
+	totalWeight := r.totalWeight()
+
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	shares := make([]models.NodeShare, 0, len(r.weights))
+	for name, weight := range r.weights {
+		shares = append(shares, models.NodeShare{
+			NodeName:      name,
+			Weight:        weight,
+			VirtualNodes:  len(r.vnodes[name]),
+			Keys:          len(hr.hashes[name]),
+			Load:          hr.loads[name],
+			Share:         float64(owned[name]) / ringSize,
+			ExpectedShare: float64(weight) / float64(totalWeight),
+		})
 	}
-	return hr.hashes[nodeName][idx:]
+	sort.Slice(shares, func(i, j int) bool { return shares[i].NodeName < shares[j].NodeName })
+	return models.Distribution{RingVersion: r.Version, Nodes: shares}
 }
 
-func (hr *HashRing) GetAllTheHashGreaterThanOrEqualToThisHash(nodeName string, hash int) []int {
-	mu.RLock()
-	defer mu.RUnlock()
-	// Binary search to find the first node hash >= key hash
-	idx := sort.Search(len(hr.nodes), func(i int) bool {
-		return hr.nodes[i] >= hash
-	})
-	// If we've reached the end of the ring, wrap around to the first node
-	if idx == len(hr.nodes) {
-		idx = 0
+func (hr *HashRing) AddHashToNode(nodeName string, hash int) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	// hashes are kept sorted, so writing the same user twice is a no-op
+	idx := sort.SearchInts(hr.hashes[nodeName], hash)
+	if idx == len(hr.hashes[nodeName]) || hr.hashes[nodeName][idx] != hash {
+		hr.hashes[nodeName] = append(hr.hashes[nodeName], hash)
+		sort.Ints(hr.hashes[nodeName])
 	}
-	return hr.hashes[nodeName][:idx]
+}
+
+// GetHashesForNode returns a copy of the key hashes stored on nodeName
+func (hr *HashRing) GetHashesForNode(nodeName string) []int {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+	return append([]int(nil), hr.hashes[nodeName]...)
 }
@@ -0,0 +1,153 @@
+package algo
+
+import (
+	"math"
+
+	"github.com/AVVKavvk/consistent-hashing/models"
+)
+
+// DefaultEpsilon lets a node own up to 25% more than its fair share
+const DefaultEpsilon = 0.25
+
+// SetBoundedLoad turns consistent hashing with bounded loads on or off.
+// Keys that already spilled keep their owner either way.
+func (hr *HashRing) SetBoundedLoad(config models.BoundedLoad) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.boundedLoad = config.Enabled
+	hr.epsilon = config.Epsilon
+}
+
+func (hr *HashRing) GetBoundedLoad() models.BoundedLoadStatus {
+	r := hr.ring.Load()
+
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	loads := make(map[string]int, len(r.weights))
+	capacities := make(map[string]int, len(r.weights))
+	for name := range r.weights {
+		loads[name] = hr.loads[name]
+		capacities[name] = hr.capacity(r, name, 0)
+	}
+	return models.BoundedLoadStatus{
+		BoundedLoad: models.BoundedLoad{Enabled: hr.boundedLoad, Epsilon: hr.epsilon},
+		TotalKeys:   len(hr.primaries),
+		Loads:       loads,
+		Capacities:  capacities,
+		Spilled:     hr.spilled,
+		RingVersion: r.Version,
+	}
+}
+
+// ClaimOwner picks the owner for a write. A known key keeps its owner. A new
+// key goes to its natural owner unless bounded loads are on and that node is
+// at capacity, then it spills clockwise to the first node with room.
+// isNew tells the caller to ReleaseOwner if the write fails.
+func (hr *HashRing) ClaimOwner(key string) (serverName string, hashOfKey int, isNew bool) {
+	hash := GetHashForKeyForGettingOwner(key)
+	r := hr.ring.Load()
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	if _, ok := hr.primaries[hash]; ok {
+		return hr.primaryForHash(r, hash), hash, false
+	}
+
+	serverName = r.owner(hash, "")
+	if hr.boundedLoad && serverName != "" && hr.loads[serverName] >= hr.capacity(r, serverName, 1) {
+		serverName = hr.spillForHash(r, hash)
+		if serverName != r.owner(hash, "") {
+			hr.storeSpill(hash, serverName)
+		}
+	}
+	if serverName == "" {
+		return "", hash, false
+	}
+
+	hr.primaries[hash] = serverName
+	hr.loads[serverName]++
+	return serverName, hash, true
+}
+
+// ReleaseOwner undoes a ClaimOwner whose write failed
+func (hr *HashRing) ReleaseOwner(hash int) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	// DeleteNode swaps the ring under mu, so r matches the bookkeeping
+	r := hr.ring.Load()
+
+	if owner, ok := hr.primaries[hash]; ok {
+		hr.decrementLoad(r, owner)
+		delete(hr.primaries, hash)
+	}
+	if _, ok := hr.spills.LoadAndDelete(hash); ok {
+		hr.spilled--
+	}
+}
+
+// AssignOwner records the owner a rebalance settled on for a stored key
+func (hr *HashRing) AssignOwner(hash int, serverName string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	// DeleteNode swaps the ring under mu, so r matches the bookkeeping
+	r := hr.ring.Load()
+
+	if owner, ok := hr.primaries[hash]; ok {
+		if owner == serverName {
+			return
+		}
+		hr.decrementLoad(r, owner)
+	}
+	hr.primaries[hash] = serverName
+	hr.loads[serverName]++
+}
+
+// decrementLoad takes one key off nodeName's load. A node that left the ring
+// has no load to take from, and one that rejoins under the same name must
+// not start below zero. Callers hold mu.
+func (hr *HashRing) decrementLoad(r *Ring, nodeName string) {
+	if r.Has(nodeName) && hr.loads[nodeName] > 0 {
+		hr.loads[nodeName]--
+	}
+}
+
+// storeSpill records that hash spilled to serverName. Callers hold mu.
+func (hr *HashRing) storeSpill(hash int, serverName string) {
+	if _, loaded := hr.spills.Swap(hash, serverName); !loaded {
+		hr.spilled++
+	}
+}
+
+// primaryForHash is the spill target if the key spilled, else the natural
+// owner on r. It only reads spills, so it needs no lock.
+func (hr *HashRing) primaryForHash(r *Ring, hash int) string {
+	if spill, ok := hr.spills.Load(hash); ok {
+		return spill.(string)
+	}
+	return r.owner(hash, "")
+}
+
+// spillForHash walks clockwise from hash to the first node below capacity.
+// Callers hold mu.
+func (hr *HashRing) spillForHash(r *Ring, hash int) string {
+	for _, name := range r.walkClockwise(hash, len(r.weights), "") {
+		if hr.loads[name] < hr.capacity(r, name, 1) {
+			return name
+		}
+	}
+	// every node is full, which (1+epsilon) > 1 rules out; keep the owner
+	return r.owner(hash, "")
+}
+
+// capacity is ceil((1+epsilon) * (keys+extra) * weight / totalWeight): a
+// node's weighted share of the keys, plus the slack. Callers hold mu.
+func (hr *HashRing) capacity(r *Ring, nodeName string, extra int) int {
+	totalWeight := r.totalWeight()
+	if totalWeight == 0 {
+		return 0
+	}
+	share := float64(len(hr.primaries)+extra) * float64(r.weights[nodeName]) / float64(totalWeight)
+	return int(math.Ceil((1 + hr.epsilon) * share))
+}
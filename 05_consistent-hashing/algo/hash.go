@@ -1,12 +1,50 @@
 package algo
 
-import "hash/crc32"
+import (
+	"hash/crc32"
+	"strconv"
+
+	"github.com/AVVKavvk/consistent-hashing/placement"
+)
+
+// hashFunc places nodes and keys on the ring. It is chosen once at startup:
+// switching it later moves every key, and keys already in Redis are orphaned.
+var hashFunc placement.HashFunc = func(data []byte) uint64 {
+	return uint64(crc32.ChecksumIEEE(data))
+}
+
+// UseHashFunction selects crc32, fnv, murmur3 or xxhash for the ring. Hashes
+// are truncated to 32 bits so the ring size stays 2^32.
+func UseHashFunction(name string) error {
+	h, err := placement.GetHashFunc(name)
+	if err != nil {
+		return err
+	}
+	hashFunc = h
+	return nil
+}
 
 func GetHashForNode(nodeName string) int {
-	hash := int(crc32.ChecksumIEEE([]byte(nodeName)))
+	hash := int(uint32(hashFunc([]byte(nodeName))))
 	return hash
 }
 func GetHashForKeyForGettingOwner(key string) int {
-	hash := int(crc32.ChecksumIEEE([]byte(key)))
+	hash := int(uint32(hashFunc([]byte(key))))
 	return hash
 }
+
+// GetHashForVirtualNode places the i-th virtual node of a server. crc32 is
+// linear, so "A#1", "A#2", ... land in clusters; the murmur3 finalizer
+// spreads them over the ring. Virtual node 0 stays at GetHashForNode.
+func GetHashForVirtualNode(nodeName string, i int) int {
+	if i == 0 {
+		return GetHashForNode(nodeName)
+	}
+	h := uint32(hashFunc([]byte(nodeName + "#" + strconv.Itoa(i))))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return int(h)
+}
@@ -0,0 +1,70 @@
+package algo
+
+// GetKeyHolders returns every stored key hash with the servers that hold a copy
+func (hr *HashRing) GetKeyHolders() map[int][]string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	holders := make(map[int][]string)
+	for nodeName, hashes := range hr.hashes {
+		for _, hash := range hashes {
+			holders[hash] = append(holders[hash], nodeName)
+		}
+	}
+	return holders
+}
+
+// RemoveHashFromNode drops a key hash and its user id from a node's bookkeeping
+func (hr *HashRing) RemoveHashFromNode(nodeName string, hash int, userId string) {
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	hr.hashes[nodeName] = removeInt(hr.hashes[nodeName], hash)
+	hr.userIds[nodeName] = removeString(hr.userIds[nodeName], userId)
+}
+
+// StartJoin marks nodeName as receiving keys, so reads that miss on it can
+// fall back to the previous owner
+func (hr *HashRing) StartJoin(nodeName string) {
+	hr.mu.Lock()
+	hr.joining[nodeName] = true
+	hr.mu.Unlock()
+}
+
+func (hr *HashRing) FinishJoin(nodeName string) {
+	hr.mu.Lock()
+	delete(hr.joining, nodeName)
+	hr.mu.Unlock()
+}
+
+// GetFallbackOwner returns who owned hash before owner joined, or "" if owner
+// is not joining
+func (hr *HashRing) GetFallbackOwner(owner string, hash int) string {
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	if !hr.joining[owner] {
+		return ""
+	}
+	return hr.ring.Load().owner(hash, owner)
+}
+
+func removeInt(values []int, value int) []int {
+	out := make([]int, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func removeString(values []string, value string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != value {
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,124 @@
+package algo
+
+import "sort"
+
+// Ring is an immutable view of the ring membership. Membership changes
+// build a new Ring and swap it in, so lookups never take a lock and a
+// request that holds a Ring sees one consistent membership.
+type Ring struct {
+	Version uint64           // bumped by every AddNode/DeleteNode
+	nodes   []int            // Sorted list of virtual node hashes
+	nodeMap map[int]string   // Maps virtual node hash to physical node name
+	vnodes  map[string][]int // Maps physical node name to its virtual node hashes
+	weights map[string]int   // Maps physical node name to its weight
+	perUnit map[string]int   // Maps physical node name to the virtual nodes per unit of weight it was added with
+}
+
+func emptyRing() *Ring {
+	return &Ring{
+		nodes:   make([]int, 0),
+		nodeMap: make(map[int]string),
+		vnodes:  make(map[string][]int),
+		weights: make(map[string]int),
+		perUnit: make(map[string]int),
+	}
+}
+
+// clone copies the ring for a membership change. vnode slices are never
+// modified once published, so they are shared.
+func (r *Ring) clone() *Ring {
+	next := &Ring{
+		Version: r.Version + 1,
+		nodes:   append(make([]int, 0, len(r.nodes)), r.nodes...),
+		nodeMap: make(map[int]string, len(r.nodeMap)),
+		vnodes:  make(map[string][]int, len(r.vnodes)),
+		weights: make(map[string]int, len(r.weights)),
+		perUnit: make(map[string]int, len(r.perUnit)),
+	}
+	for k, v := range r.nodeMap {
+		next.nodeMap[k] = v
+	}
+	for k, v := range r.vnodes {
+		next.vnodes[k] = v
+	}
+	for k, v := range r.weights {
+		next.weights[k] = v
+	}
+	for k, v := range r.perUnit {
+		next.perUnit[k] = v
+	}
+	return next
+}
+
+// Has reports whether nodeName is a member
+func (r *Ring) Has(nodeName string) bool {
+	_, ok := r.weights[nodeName]
+	return ok
+}
+
+// owner walks clockwise from hash and returns the first server that is not exclude
+func (r *Ring) owner(hash int, exclude string) string {
+	if len(r.nodes) == 0 {
+		return ""
+	}
+	// Binary search to find the first node hash >= key hash
+	idx := sort.Search(len(r.nodes), func(i int) bool {
+		return r.nodes[i] >= hash
+	})
+	for step := 0; step < len(r.nodes); step++ {
+		// If we've reached the end of the ring, wrap around to the first node
+		name := r.nodeMap[r.nodes[(idx+step)%len(r.nodes)]]
+		if name != exclude {
+			return name
+		}
+	}
+	return ""
+}
+
+// walkClockwise lists n distinct servers clockwise from hash, starting with
+// first when it is a member
+func (r *Ring) walkClockwise(hash int, n int, first string) []string {
+	if n > len(r.weights) {
+		n = len(r.weights)
+	}
+	list := make([]string, 0, n)
+	if n == 0 {
+		return list
+	}
+
+	seen := make(map[string]bool, n)
+	if first != "" && r.Has(first) {
+		seen[first] = true
+		list = append(list, first)
+	}
+
+	idx := sort.Search(len(r.nodes), func(i int) bool {
+		return r.nodes[i] >= hash
+	})
+	for step := 0; step < len(r.nodes) && len(list) < n; step++ {
+		name := r.nodeMap[r.nodes[(idx+step)%len(r.nodes)]]
+		if !seen[name] {
+			seen[name] = true
+			list = append(list, name)
+		}
+	}
+	return list
+}
+
+// Nodes returns the member names, sorted
+func (r *Ring) Nodes() []string {
+	names := make([]string, 0, len(r.weights))
+	for name := range r.weights {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *Ring) totalWeight() int {
+	total := 0
+	for _, w := range r.weights {
+		total += w
+	}
+	return total
+}
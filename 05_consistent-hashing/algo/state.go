@@ -0,0 +1,130 @@
+package algo
+
+import (
+	"sort"
+
+	"github.com/AVVKavvk/consistent-hashing/models"
+)
+
+// Export returns the membership and per-node key bookkeeping, for persisting
+func (hr *HashRing) Export() models.RingState {
+	r := hr.ring.Load()
+
+	nodes := make([]models.CreateServer, 0, len(r.weights))
+	for name, weight := range r.weights {
+		nodes = append(nodes, models.CreateServer{Name: name, VirtualNodes: r.perUnit[name], Weight: weight})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+
+	hr.mu.RLock()
+	defer hr.mu.RUnlock()
+
+	state := models.RingState{
+		Nodes:   nodes,
+		Hashes:  make(map[string][]int, len(hr.hashes)),
+		UserIds: make(map[string][]string, len(hr.userIds)),
+		Spills:  make(map[int]string, hr.spilled),
+	}
+	for name, hashes := range hr.hashes {
+		state.Hashes[name] = append([]int(nil), hashes...)
+	}
+	for name, userIds := range hr.userIds {
+		state.UserIds[name] = append([]string(nil), userIds...)
+	}
+	hr.spills.Range(func(hash, spill any) bool {
+		state.Spills[hash.(int)] = spill.(string)
+		return true
+	})
+	return state
+}
+
+// Restore adds the nodes of a persisted state and loads its bookkeeping,
+// including where spilled keys went
+func (hr *HashRing) Restore(state models.RingState) uint64 {
+	version := hr.Version()
+	for _, node := range state.Nodes {
+		version = hr.AddNode(node.Name, node.VirtualNodes, node.Weight)
+	}
+
+	r := hr.ring.Load()
+	for name, hashes := range state.Hashes {
+		if r.Has(name) {
+			hr.SetNodeKeys(name, hashes, state.UserIds[name])
+		}
+	}
+	hr.mu.Lock()
+	for hash, spill := range state.Spills {
+		if r.Has(spill) {
+			hr.storeSpill(hash, spill)
+		}
+	}
+	hr.mu.Unlock()
+	hr.RecountOwners()
+	return version
+}
+
+// SetNodeKeys replaces the key bookkeeping of nodeName
+func (hr *HashRing) SetNodeKeys(nodeName string, hashes []int, userIds []string) {
+	hashes = append([]int(nil), hashes...)
+	sort.Ints(hashes)
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+	hr.hashes[nodeName] = hashes
+	hr.userIds[nodeName] = append([]string(nil), userIds...)
+}
+
+// RecountOwners rebuilds the per-key owner accounting that bounded loads
+// use from the stored hashes. A key its natural owner does not hold spilled:
+// its owner is the first server clockwise that holds it, the same walk
+// ClaimOwner made, and the spill is recorded so reads find it. A recorded
+// spill whose target no longer holds the key is dropped.
+func (hr *HashRing) RecountOwners() {
+	r := hr.ring.Load()
+
+	hr.mu.Lock()
+	defer hr.mu.Unlock()
+
+	holders := make(map[int]map[string]bool)
+	for name, hashes := range hr.hashes {
+		for _, hash := range hashes {
+			if holders[hash] == nil {
+				holders[hash] = make(map[string]bool)
+			}
+			holders[hash][name] = true
+		}
+	}
+
+	spills := make(map[int]string)
+	hr.primaries = make(map[int]string)
+	hr.loads = make(map[string]int)
+	for hash, held := range holders {
+		owner := r.owner(hash, "")
+		if spill, ok := hr.spills.Load(hash); ok && held[spill.(string)] {
+			owner = spill.(string)
+		} else if !held[owner] {
+			for _, name := range r.walkClockwise(hash, len(r.weights), "") {
+				if held[name] {
+					owner = name
+					break
+				}
+			}
+		}
+		if owner != r.owner(hash, "") {
+			spills[hash] = owner
+		}
+		hr.primaries[hash] = owner
+		hr.loads[owner]++
+	}
+
+	hr.spills.Range(func(hash, spill any) bool {
+		if spills[hash.(int)] != spill {
+			hr.spills.Delete(hash)
+		}
+		return true
+	})
+	for hash, spill := range spills {
+		hr.spills.Store(hash, spill)
+	}
+	hr.spilled = len(spills)
+}
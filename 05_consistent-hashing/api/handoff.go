@@ -0,0 +1,56 @@
+package api
+
+import (
+	"github.com/AVVKavvk/consistent-hashing/models"
+	"github.com/AVVKavvk/consistent-hashing/service"
+	"github.com/labstack/echo/v4"
+)
+
+// GetHints godoc
+// @Summary Hinted handoff metrics
+// @Description Hints pending (total and per unreachable owner), stored, replayed and failed replays
+// @Tags hints
+// @Produce  json
+// @Success 200 {object} models.HintStats
+// @Router /hints [get]
+func GetHints(ctx echo.Context) error {
+	result, err := service.GetHintStatsService()
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
+
+// ReplayHints godoc
+// @Summary Replay hints now
+// @Description Moves every handed off copy whose owner is reachable again back to the owner, without waiting for the background replay
+// @Tags hints
+// @Produce  json
+// @Success 200 {object} models.HintStats
+// @Router /hints/replay [post]
+func ReplayHints(ctx echo.Context) error {
+	result, err := service.ReplayHintsService()
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
+
+// SetServerHealth godoc
+// @Summary Mark a server reachable or unreachable
+// @Description Every Redis operation on an unreachable server fails, so writes for it are handed off
+// @Tags servers
+// @Accept  json
+// @Produce  json
+// @Param name path string true "Server Name"
+// @Param health body models.ServerHealth true "Reachability"
+// @Success 200 {object} models.ServerHealth
+// @Router /servers/{name}/health [put]
+func SetServerHealth(ctx echo.Context) error {
+	var health models.ServerHealth
+	if err := ctx.Bind(&health); err != nil {
+		return err
+	}
+	result := service.SetServerHealthService(ctx.Param("name"), &health)
+	return ctx.JSON(200, result)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/AVVKavvk/consistent-hashing/models"
@@ -10,26 +11,33 @@ import (
 
 // AddServer godoc
 // @Summary Add a new physical/virtual server to the ring
-// @Description Adds a server and re-distributes keys based on consistent hashing
+// @Description Adds a server with virtualNodes*weight points on the ring (defaults: 100 virtual nodes, weight 1)
 // @Tags servers
 // @Accept  json
 // @Produce  json
 // @Param server body models.CreateServer true "Server Details"
 // @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Router /servers [post]
 func AddServer(ctx echo.Context) error {
 	var server models.CreateServer
 	if err := ctx.Bind(&server); err != nil {
 		return err
 	}
-	result := service.AddServerService(&server)
+	result, err := service.AddServerService(&server)
+	if errors.Is(err, service.ErrInvalidServer) {
+		return ctx.JSON(400, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
 
 	return ctx.JSON(201, result)
 }
 
 // DeleServer godoc
 // @Summary Remove a server from the ring
-// @Description Removes a server by name and migrates its keys to the next available node
+// @Description Removes a server by name and migrates each of its keys to the key's new owner
 // @Tags servers
 // @Param name path string true "Server Name"
 // @Success 200 {string} string "ok"
@@ -52,6 +60,56 @@ func GetAllServer(ctx echo.Context) error {
 	return ctx.JSON(200, result)
 }
 
+// GetDistribution godoc
+// @Summary Share of the hash space per server
+// @Description For each physical server: weight, virtual node count, stored keys, the fraction of the ring it owns and the fraction its weight asks for
+// @Tags servers
+// @Produce  json
+// @Success 200 {object} models.Distribution
+// @Router /servers/distribution [get]
+func GetDistribution(ctx echo.Context) error {
+	result := service.GetDistributionService()
+	return ctx.JSON(200, result)
+}
+
+// GetBoundedLoad godoc
+// @Summary Bounded-load settings and live loads
+// @Description Whether bounded loads are on, epsilon, keys owned per server and each server's current capacity
+// @Tags servers
+// @Produce  json
+// @Success 200 {object} models.BoundedLoadStatus
+// @Router /servers/bounded-load [get]
+func GetBoundedLoad(ctx echo.Context) error {
+	return ctx.JSON(200, service.GetBoundedLoadService())
+}
+
+// SetBoundedLoad godoc
+// @Summary Configure bounded loads
+// @Description When enabled, no server owns more than (1+epsilon) times its weighted share of keys; new keys whose owner is full spill to the next server clockwise
+// @Tags servers
+// @Accept  json
+// @Produce  json
+// @Param config body models.BoundedLoad true "Bounded-load settings"
+// @Success 200 {object} models.BoundedLoadStatus
+// @Failure 400 {object} map[string]string
+// @Router /servers/bounded-load [put]
+func SetBoundedLoad(ctx echo.Context) error {
+	// Start from the current settings so fields left out of the body keep
+	// their value, e.g. {"enabled":true} keeps epsilon
+	config := service.GetBoundedLoadService().BoundedLoad
+	if err := ctx.Bind(&config); err != nil {
+		return err
+	}
+	result, err := service.SetBoundedLoadService(&config)
+	if errors.Is(err, service.ErrInvalidEpsilon) {
+		return ctx.JSON(400, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
+
 // GetServerInfo godoc
 // @Summary Get information about a specific server
 // @Description Retrieves information about a specific server based on its name
@@ -69,3 +127,18 @@ func GetServerInfo(ctx echo.Context) error {
 	result := service.GetInfoOfServerByName(name)
 	return ctx.JSON(200, result)
 }
+
+// Reconcile godoc
+// @Summary Repair the server bookkeeping from Redis
+// @Description Scans the serverName:hash keys in Redis and rebuilds every server's hashes and userIds from them
+// @Tags servers
+// @Produce  json
+// @Success 200 {object} models.ReconcileReport
+// @Router /servers/reconcile [post]
+func Reconcile(ctx echo.Context) error {
+	result, err := service.ReconcileService()
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
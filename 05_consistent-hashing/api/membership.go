@@ -0,0 +1,27 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/AVVKavvk/consistent-hashing/service"
+	"github.com/labstack/echo/v4"
+)
+
+// GetMembers godoc
+// @Summary List the gossip members
+// @Description Every service instance this instance knows of, with its SWIM state and incarnation
+// @Tags membership
+// @Produce  json
+// @Success 200 {array} models.Member
+// @Failure 400 {object} map[string]string
+// @Router /members [get]
+func GetMembers(ctx echo.Context) error {
+	result, err := service.GetMembersService()
+	if errors.Is(err, service.ErrMembershipDisabled) {
+		return ctx.JSON(400, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/AVVKavvk/consistent-hashing/models"
+	"github.com/AVVKavvk/consistent-hashing/service"
+	"github.com/labstack/echo/v4"
+)
+
+// GetReplication godoc
+// @Summary Get the replication settings
+// @Description N copies per key, R replicas per read, W replicas per write
+// @Tags replication
+// @Produce  json
+// @Success 200 {object} models.ReplicationConfig
+// @Router /replication [get]
+func GetReplication(ctx echo.Context) error {
+	return ctx.JSON(200, service.GetReplicationService())
+}
+
+// SetReplication godoc
+// @Summary Change the replication settings
+// @Description Sets N, R and W. Needs 1 <= R <= N and 1 <= W <= N; R + W > N gives read-your-writes.
+// @Tags replication
+// @Accept  json
+// @Produce  json
+// @Param config body models.ReplicationConfig true "Replication settings"
+// @Success 200 {object} models.ReplicationConfig
+// @Failure 400 {object} map[string]string
+// @Router /replication [put]
+func SetReplication(ctx echo.Context) error {
+	var config models.ReplicationConfig
+	if err := ctx.Bind(&config); err != nil {
+		return err
+	}
+	result, err := service.SetReplicationService(&config)
+	if errors.Is(err, service.ErrInvalidReplication) {
+		return ctx.JSON(400, map[string]string{"message": err.Error()})
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.JSON(200, result)
+}
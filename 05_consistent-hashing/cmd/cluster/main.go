@@ -0,0 +1,195 @@
+// Command cluster runs several gossip instances in one process on loopback
+// and checks that their rings converge: after everyone joins, after one
+// instance crashes and after one leaves. Each instance keeps its own
+// algo.HashRing, fed by membership.Handlers the way the service's ring is,
+// and exactly one instance must be elected to hand off the keys of each
+// member that goes away.
+//
+//	go run ./cmd/cluster -instances 5
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/AVVKavvk/consistent-hashing/algo"
+	"github.com/AVVKavvk/consistent-hashing/gossip"
+	"github.com/AVVKavvk/consistent-hashing/membership"
+)
+
+// instance is one in-process service instance
+type instance struct {
+	name string
+	list *gossip.Memberlist
+	ring *algo.HashRing
+}
+
+// owners reads the ring without a lock, the same way the service does
+func (in *instance) owners(keys []string) ([]string, int) {
+	r := in.ring.Snapshot()
+
+	owners := make([]string, len(keys))
+	for i, key := range keys {
+		owners[i], _ = in.ring.GetOwner(key)
+	}
+	return owners, len(r.Nodes())
+}
+
+// handoffs records which instances handed off each departed member's keys
+type handoffs struct {
+	mu sync.Mutex
+	by map[string][]string
+}
+
+func (h *handoffs) add(member, by string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.by[member] = append(h.by[member], by)
+}
+
+func (h *handoffs) get(member string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]string(nil), h.by[member]...)
+}
+
+func main() {
+	count := flag.Int("instances", 5, "number of instances to start")
+	keyCount := flag.Int("keys", 1000, "keys compared across the rings")
+	probe := flag.Duration("probe", 100*time.Millisecond, "probe interval")
+	timeout := flag.Duration("timeout", 10*time.Second, "how long to wait for each convergence")
+	flag.Parse()
+
+	if *count < 3 {
+		log.Fatal("need at least 3 instances: one crashes, one leaves")
+	}
+
+	keys := make([]string, *keyCount)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	quiet := log.New(os.Stderr, "", log.LstdFlags)
+	elected := &handoffs{by: make(map[string][]string)}
+
+	var err error
+	instances := make([]*instance, *count)
+	for i := range instances {
+		in := &instance{name: "node-" + strconv.Itoa(i), ring: algo.NewHashRing()}
+
+		config := gossip.DefaultConfig(in.name, "127.0.0.1:0")
+		config.ProbeInterval = *probe
+		config.ProbeTimeout = *probe / 2
+		config.SuspicionTimeout = 5 * *probe
+		config.PushPullInterval = 10 * *probe
+		config.Logger = quiet
+
+		// the service runs AddServerService and DeleteServerService when elected
+		handlers := &membership.Handlers{
+			Ring:   in.ring,
+			Self:   in.name,
+			Settle: config.PushPullInterval,
+			Joined: func(member gossip.Member) {
+				in.ring.AddNode(member.Name, member.VirtualNodes, member.Weight)
+			},
+			Left: func(member gossip.Member) {
+				elected.add(member.Name, in.name)
+				in.ring.DeleteNode(member.Name)
+			},
+		}
+		if in.list, err = handlers.NewMemberlist(config); err != nil {
+			log.Fatal(err)
+		}
+		instances[i] = in
+	}
+	defer func() {
+		for _, in := range instances {
+			in.list.Shutdown()
+		}
+	}()
+
+	// everyone joins through the first instance
+	start := time.Now()
+	for _, in := range instances[1:] {
+		if err := in.list.Join([]string{instances[0].list.Addr()}); err != nil {
+			log.Fatal(err)
+		}
+	}
+	report("join", instances, keys, *count, start, *timeout)
+
+	crashed := instances[1]
+	start = time.Now()
+	crashed.list.Shutdown()
+	report("crash of "+crashed.name, without(instances, crashed), keys, *count-1, start, *timeout)
+	checkHandoff(crashed.name, elected)
+
+	left := instances[2]
+	start = time.Now()
+	left.list.Leave()
+	left.list.Shutdown()
+	report("leave of "+left.name, without(instances, crashed, left), keys, *count-2, start, *timeout)
+	checkHandoff(left.name, elected)
+}
+
+// checkHandoff fails unless exactly one instance handed off member's keys.
+// Every leave callback has run once the rings converged.
+func checkHandoff(member string, elected *handoffs) {
+	by := elected.get(member)
+	if len(by) != 1 {
+		log.Fatalf("%s was handed off by %v, want exactly one instance", member, by)
+	}
+	fmt.Printf("%-16s handed off by %s\n", "", by[0])
+}
+
+// report waits until every instance has nodes members on its ring and all
+// rings place every key on the same node
+func report(step string, instances []*instance, keys []string, nodes int, start time.Time, timeout time.Duration) {
+	for time.Since(start) < timeout {
+		if converged(instances, keys, nodes) {
+			fmt.Printf("%-16s converged on %d nodes in %v\n", step, nodes, time.Since(start).Round(time.Millisecond))
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, in := range instances {
+		fmt.Printf("  %s sees %v\n", in.name, in.list.Members())
+	}
+	log.Fatalf("%s: no convergence within %v", step, timeout)
+}
+
+func converged(instances []*instance, keys []string, nodes int) bool {
+	want, n := instances[0].owners(keys)
+	if n != nodes {
+		return false
+	}
+	for _, in := range instances[1:] {
+		got, n := in.owners(keys)
+		if n != nodes {
+			return false
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func without(instances []*instance, skip ...*instance) []*instance {
+	out := make([]*instance, 0, len(instances))
+	for _, in := range instances {
+		keep := true
+		for _, s := range skip {
+			keep = keep && in != s
+		}
+		if keep {
+			out = append(out, in)
+		}
+	}
+	return out
+}
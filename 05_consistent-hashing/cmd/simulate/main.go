@@ -0,0 +1,125 @@
+// Command simulate compares the placement algorithms: how evenly keys spread
+// over the nodes, and how many keys move when a node is added or removed.
+//
+//	go run ./cmd/simulate -keys 100000 -nodes 10 -hash xxhash
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AVVKavvk/consistent-hashing/placement"
+)
+
+func main() {
+	keyCount := flag.Int("keys", 100000, "number of keys to place")
+	nodeCount := flag.Int("nodes", 10, "number of nodes before the change")
+	hashName := flag.String("hash", "xxhash", "hash function: "+strings.Join(placement.HashFuncNames(), ", "))
+	virtualNodes := flag.Int("vnodes", 100, "virtual nodes per node for ring and bounded")
+	epsilon := flag.Float64("epsilon", 0.25, "load slack for bounded, max load is (1+epsilon)*average")
+	algos := flag.String("algos", strings.Join(placement.Names, ","), "comma separated algorithms to compare")
+	flag.Parse()
+
+	hash, err := placement.GetHashFunc(*hashName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	keys := make([]string, *keyCount)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	nodes := make([]string, *nodeCount)
+	for i := range nodes {
+		nodes[i] = "node-" + strconv.Itoa(i)
+	}
+
+	build := func(name string) placement.Placement {
+		p, err := placement.New(name, hash, *virtualNodes, *epsilon)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, node := range nodes {
+			p.Add(node)
+		}
+		return p
+	}
+
+	fmt.Printf("keys: %d, nodes: %d, hash: %s\n", *keyCount, *nodeCount, *hashName)
+	fmt.Printf("ideal movement: add %.2f%%, remove %.2f%%\n\n", 100/float64(*nodeCount+1), 100/float64(*nodeCount))
+	fmt.Printf("%-11s %10s %10s %10s %12s %12s\n", "algorithm", "lookup ns", "stddev %", "max/avg", "moved add %", "moved rm %")
+
+	for _, name := range strings.Split(*algos, ",") {
+		p := build(name)
+		before := locateAll(p, keys)
+		stddev, maxRatio := loadStats(before, nodes)
+
+		lookup := testing.Benchmark(func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				p.Locate(keys[i%len(keys)])
+			}
+		})
+
+		// add one node to the original set
+		added := build(name)
+		added.Add("node-" + strconv.Itoa(*nodeCount))
+		movedAdd := moved(before, locateAll(added, keys))
+
+		// remove a node from the middle of the original set
+		removed := build(name)
+		removed.Remove(nodes[len(nodes)/2])
+		movedRemove := moved(before, locateAll(removed, keys))
+
+		fmt.Printf("%-11s %10d %10.2f %10.3f %12.2f %12.2f\n",
+			name,
+			lookup.NsPerOp(),
+			100*stddev,
+			maxRatio,
+			100*float64(movedAdd)/float64(len(keys)),
+			100*float64(movedRemove)/float64(len(keys)),
+		)
+	}
+}
+
+func locateAll(p placement.Placement, keys []string) []string {
+	owners := make([]string, len(keys))
+	for i, key := range keys {
+		owners[i] = p.Locate(key)
+	}
+	return owners
+}
+
+// loadStats returns the relative standard deviation of keys per node and the
+// most loaded node over the average
+func loadStats(owners []string, nodes []string) (stddev float64, maxRatio float64) {
+	load := make(map[string]int, len(nodes))
+	for _, owner := range owners {
+		load[owner]++
+	}
+	avg := float64(len(owners)) / float64(len(nodes))
+
+	variance, maxLoad := 0.0, 0
+	for _, node := range nodes {
+		diff := float64(load[node]) - avg
+		variance += diff * diff
+		if load[node] > maxLoad {
+			maxLoad = load[node]
+		}
+	}
+	return math.Sqrt(variance/float64(len(nodes))) / avg, float64(maxLoad) / avg
+}
+
+func moved(before []string, after []string) int {
+	count := 0
+	for i := range before {
+		if before[i] != after[i] {
+			count++
+		}
+	}
+	return count
+}
@@ -12,7 +12,9 @@ require (
 )
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/labstack/echo/v4 v4.15.0
+	github.com/spaolacci/murmur3 v1.1.0
 	github.com/swaggo/echo-swagger v1.4.1
 	github.com/swaggo/swag v1.16.6
 )
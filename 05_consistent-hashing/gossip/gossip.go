@@ -0,0 +1,635 @@
+// Package gossip is a SWIM-style membership protocol over UDP. Every
+// instance probes one random member per ProbeInterval, asks IndirectChecks
+// other members to probe it when the direct ping times out, and marks it
+// suspect if nobody gets an ack. A suspect that does not refute the
+// suspicion within SuspicionTimeout is declared dead. Membership changes
+// are piggybacked on the probe traffic, and a periodic full sync with one
+// random member repairs anything the piggybacking missed.
+//
+// A Memberlist holds no package state, so several can run in one process on
+// loopback, see cmd/cluster and gossip_test.go.
+package gossip
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxPiggyback is how many updates ride on one message
+const maxPiggyback = 8
+
+var ErrNoSeeds = errors.New("could not reach any seed")
+
+type Config struct {
+	// Name identifies the instance and is its node name on the ring
+	Name string
+	// BindAddr is the UDP host:port to listen on, port 0 picks a free one
+	BindAddr string
+	// AdvertiseAddr is the address other members reach us on, defaults to
+	// the bound address
+	AdvertiseAddr string
+	Weight        int
+	VirtualNodes  int
+
+	ProbeInterval    time.Duration
+	ProbeTimeout     time.Duration
+	SuspicionTimeout time.Duration
+	PushPullInterval time.Duration
+	// IndirectChecks is how many members are asked to ping a target that
+	// did not answer
+	IndirectChecks int
+	// RetransmitMult scales how often an update is piggybacked:
+	// RetransmitMult * log10(members+1) times
+	RetransmitMult int
+
+	// OnJoin is called when a member becomes alive, including this instance.
+	// OnLeave is called when a member is declared dead or leaves. They run
+	// one at a time on their own goroutine, in the order the changes happened.
+	OnJoin  func(Member)
+	OnLeave func(Member)
+
+	Logger *log.Logger
+}
+
+// DefaultConfig returns timings suited to a LAN
+func DefaultConfig(name, bindAddr string) Config {
+	return Config{
+		Name:             name,
+		BindAddr:         bindAddr,
+		Weight:           1,
+		ProbeInterval:    time.Second,
+		ProbeTimeout:     500 * time.Millisecond,
+		SuspicionTimeout: 5 * time.Second,
+		PushPullInterval: 15 * time.Second,
+		IndirectChecks:   3,
+		RetransmitMult:   4,
+		Logger:           log.Default(),
+	}
+}
+
+// event is a join or leave waiting for the callbacks
+type event struct {
+	member Member
+	joined bool
+}
+
+type Memberlist struct {
+	config Config
+	conn   *net.UDPConn
+	seq    atomic.Uint32
+
+	mu         sync.Mutex
+	members    map[string]*Member
+	suspicions map[string]*time.Timer
+	broadcasts map[string]*broadcast
+	probeOrder []string
+	probeIdx   int
+	acks       map[uint32]chan struct{}
+
+	eventMu sync.Mutex
+	events  []event
+	notify  chan struct{}
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New starts listening and probing. The instance is alone until Join.
+func New(config Config) (*Memberlist, error) {
+	if config.Logger == nil {
+		config.Logger = log.Default()
+	}
+	if config.ProbeTimeout >= config.ProbeInterval {
+		return nil, errors.New("gossip: ProbeTimeout must be shorter than ProbeInterval")
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", config.BindAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", udpAddr)
+	if err != nil {
+		return nil, err
+	}
+	if config.AdvertiseAddr == "" {
+		config.AdvertiseAddr = conn.LocalAddr().String()
+	}
+
+	m := &Memberlist{
+		config:     config,
+		conn:       conn,
+		members:    make(map[string]*Member),
+		suspicions: make(map[string]*time.Timer),
+		broadcasts: make(map[string]*broadcast),
+		acks:       make(map[uint32]chan struct{}),
+		notify:     make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+	}
+
+	self := Member{
+		Name:         config.Name,
+		Addr:         config.AdvertiseAddr,
+		Weight:       config.Weight,
+		VirtualNodes: config.VirtualNodes,
+		State:        StateAlive,
+	}
+	m.members[self.Name] = &self
+	m.queue(self)
+	m.emit(self, true)
+
+	m.wg.Add(4)
+	go m.receiveLoop()
+	go m.probeLoop()
+	go m.syncLoop()
+	go m.eventLoop()
+	return m, nil
+}
+
+// Addr is the address other members reach this instance on
+func (m *Memberlist) Addr() string {
+	return m.config.AdvertiseAddr
+}
+
+// Join syncs the member list with every seed. It returns ErrNoSeeds when
+// no seed could be sent to; the seeds answering is not waited for.
+func (m *Memberlist) Join(seeds []string) error {
+	reached := 0
+	for _, seed := range seeds {
+		if seed == m.config.AdvertiseAddr {
+			continue
+		}
+		if err := m.send(seed, message{Type: msgSync, Members: m.snapshot()}); err != nil {
+			m.config.Logger.Printf("gossip: join %s: %v", seed, err)
+			continue
+		}
+		reached++
+	}
+	if reached == 0 && len(seeds) > 0 {
+		return ErrNoSeeds
+	}
+	return nil
+}
+
+// Members returns every member this instance knows of, dead ones included,
+// sorted by name
+func (m *Memberlist) Members() []Member {
+	members := m.snapshot()
+	sort.Slice(members, func(i, j int) bool { return members[i].Name < members[j].Name })
+	return members
+}
+
+// Alive reports whether this instance sees name as alive. Suspect members
+// are not, they may already be gone.
+func (m *Memberlist) Alive(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	member, ok := m.members[name]
+	return ok && member.State == StateAlive
+}
+
+// Leave announces that this instance leaves, so the others take it off the
+// ring right away instead of waiting for the suspicion to time out. Call
+// Shutdown afterwards.
+func (m *Memberlist) Leave() {
+	m.mu.Lock()
+	self := m.members[m.config.Name]
+	self.Incarnation++
+	self.State = StateLeft
+	m.queue(*self)
+	peers := m.randomMembers(m.config.IndirectChecks, "")
+	m.mu.Unlock()
+
+	members := m.snapshot()
+	for _, peer := range peers {
+		_ = m.send(peer.Addr, message{Type: msgSync, Members: members})
+	}
+}
+
+// Shutdown stops the protocol without telling anyone, which the other
+// members see as a crash
+func (m *Memberlist) Shutdown() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+		m.conn.Close()
+
+		m.mu.Lock()
+		for _, timer := range m.suspicions {
+			timer.Stop()
+		}
+		m.mu.Unlock()
+	})
+	m.wg.Wait()
+}
+
+func (m *Memberlist) snapshot() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	members := make([]Member, 0, len(m.members))
+	for _, member := range m.members {
+		members = append(members, *member)
+	}
+	return members
+}
+
+func (m *Memberlist) receiveLoop() {
+	defer m.wg.Done()
+
+	buf := make([]byte, 65536)
+	for {
+		n, from, err := m.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-m.stop:
+				return
+			default:
+			}
+			m.config.Logger.Printf("gossip: read: %v", err)
+			continue
+		}
+
+		var msg message
+		if err := json.Unmarshal(buf[:n], &msg); err != nil {
+			m.config.Logger.Printf("gossip: bad message from %s: %v", from, err)
+			continue
+		}
+		m.handle(msg, from.String())
+	}
+}
+
+func (m *Memberlist) handle(msg message, from string) {
+	m.merge(msg.Updates)
+
+	switch msg.Type {
+	case msgPing:
+		// a ping for someone else was sent to an address that changed hands
+		if msg.Target != m.config.Name {
+			return
+		}
+		_ = m.send(from, message{Type: msgAck, Seq: msg.Seq})
+
+	case msgPingReq:
+		seq := m.seq.Add(1)
+		ack := m.expectAck(seq)
+		_ = m.send(msg.TargetAddr, message{Type: msgPing, Seq: seq, Target: msg.Target})
+		go func() {
+			select {
+			case <-ack:
+				_ = m.send(from, message{Type: msgAck, Seq: msg.Seq})
+			case <-time.After(m.config.ProbeTimeout):
+				m.cancelAck(seq)
+			case <-m.stop:
+			}
+		}()
+
+	case msgAck:
+		m.mu.Lock()
+		if ack, ok := m.acks[msg.Seq]; ok {
+			delete(m.acks, msg.Seq)
+			close(ack)
+		}
+		m.mu.Unlock()
+
+	case msgSync:
+		m.merge(msg.Members)
+		_ = m.send(from, message{Type: msgSyncAck, Members: m.snapshot()})
+
+	case msgSyncAck:
+		m.merge(msg.Members)
+	}
+}
+
+// probeLoop probes one member per ProbeInterval
+func (m *Memberlist) probeLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.ProbeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.probe()
+		}
+	}
+}
+
+func (m *Memberlist) probe() {
+	m.mu.Lock()
+	target, ok := m.nextProbeTarget()
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	seq := m.seq.Add(1)
+	ack := m.expectAck(seq)
+	_ = m.send(target.Addr, message{Type: msgPing, Seq: seq, Target: target.Name})
+	if m.wait(ack, m.config.ProbeTimeout) {
+		return
+	}
+
+	// no direct ack: ask others to try, a lossy link between the two of us
+	// should not get the target declared dead
+	m.mu.Lock()
+	peers := m.randomMembers(m.config.IndirectChecks, target.Name)
+	m.mu.Unlock()
+	for _, peer := range peers {
+		_ = m.send(peer.Addr, message{Type: msgPingReq, Seq: seq, Target: target.Name, TargetAddr: target.Addr})
+	}
+	if m.wait(ack, m.config.ProbeInterval-m.config.ProbeTimeout) {
+		return
+	}
+	m.cancelAck(seq)
+
+	target.State = StateSuspect
+	m.merge([]Member{target})
+}
+
+// wait reports whether ack fired within timeout
+func (m *Memberlist) wait(ack chan struct{}, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ack:
+		return true
+	case <-timer.C:
+		return false
+	case <-m.stop:
+		return true
+	}
+}
+
+// syncLoop exchanges the full member list with one random member per
+// PushPullInterval
+func (m *Memberlist) syncLoop() {
+	defer m.wg.Done()
+
+	ticker := time.NewTicker(m.config.PushPullInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			peers := m.randomMembers(1, "")
+			m.mu.Unlock()
+			for _, peer := range peers {
+				_ = m.send(peer.Addr, message{Type: msgSync, Members: m.snapshot()})
+			}
+		}
+	}
+}
+
+func (m *Memberlist) expectAck(seq uint32) chan struct{} {
+	ack := make(chan struct{})
+	m.mu.Lock()
+	m.acks[seq] = ack
+	m.mu.Unlock()
+	return ack
+}
+
+func (m *Memberlist) cancelAck(seq uint32) {
+	m.mu.Lock()
+	delete(m.acks, seq)
+	m.mu.Unlock()
+}
+
+// send piggybacks pending updates on msg and writes it to addr. It must
+// not be called with mu held.
+func (m *Memberlist) send(addr string, msg message) error {
+	m.mu.Lock()
+	msg.Updates = m.piggyback()
+	m.mu.Unlock()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return err
+	}
+	_, err = m.conn.WriteToUDP(data, udpAddr)
+	return err
+}
+
+// merge applies updates from another member
+func (m *Memberlist) merge(updates []Member) {
+	if len(updates) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range updates {
+		m.apply(u)
+	}
+}
+
+// apply records update u if it is newer than what we know. Callers hold mu.
+func (m *Memberlist) apply(u Member) {
+	cur, known := m.members[u.Name]
+
+	if u.Name == m.config.Name {
+		// somebody suspects us or thinks we are dead: refute it with a
+		// higher incarnation, unless we are leaving
+		if u.State != StateAlive && cur.State == StateAlive && u.Incarnation >= cur.Incarnation {
+			cur.Incarnation = u.Incarnation + 1
+			m.queue(*cur)
+		}
+		return
+	}
+
+	if !known {
+		member := u
+		m.members[u.Name] = &member
+		m.queue(u)
+		if u.State == StateSuspect {
+			m.suspect(u)
+		}
+		if u.live() {
+			m.emit(u, true)
+		}
+		return
+	}
+
+	if !overrides(*cur, u) {
+		return
+	}
+	wasLive := cur.live()
+	*cur = u
+	m.queue(u)
+
+	if timer, ok := m.suspicions[u.Name]; ok && u.State != StateSuspect {
+		timer.Stop()
+		delete(m.suspicions, u.Name)
+	}
+	if u.State == StateSuspect {
+		m.suspect(u)
+	}
+
+	switch {
+	case !wasLive && u.live():
+		m.emit(u, true)
+	case wasLive && !u.live():
+		m.emit(u, false)
+	}
+}
+
+// suspect starts the suspicion timeout for u. Callers hold mu.
+func (m *Memberlist) suspect(u Member) {
+	if timer, ok := m.suspicions[u.Name]; ok {
+		timer.Stop()
+	}
+	m.suspicions[u.Name] = time.AfterFunc(m.config.SuspicionTimeout, func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		cur := m.members[u.Name]
+		if cur.State != StateSuspect || cur.Incarnation != u.Incarnation {
+			return
+		}
+		delete(m.suspicions, u.Name)
+		m.config.Logger.Printf("gossip: %s did not refute suspicion, declaring it dead", u.Name)
+		dead := *cur
+		dead.State = StateDead
+		m.apply(dead)
+	})
+}
+
+// queue schedules u to be piggybacked, replacing an older update about the
+// same member. Callers hold mu.
+func (m *Memberlist) queue(u Member) {
+	m.broadcasts[u.Name] = &broadcast{member: u}
+}
+
+// piggyback takes the least sent updates and drops the ones that have been
+// sent often enough to have reached everyone. Callers hold mu.
+func (m *Memberlist) piggyback() []Member {
+	if len(m.broadcasts) == 0 {
+		return nil
+	}
+	limit := m.config.RetransmitMult * int(math.Ceil(math.Log10(float64(len(m.members)+1))))
+	if limit < 1 {
+		limit = 1
+	}
+
+	pending := make([]*broadcast, 0, len(m.broadcasts))
+	for _, b := range m.broadcasts {
+		pending = append(pending, b)
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].transmits < pending[j].transmits })
+	if len(pending) > maxPiggyback {
+		pending = pending[:maxPiggyback]
+	}
+
+	updates := make([]Member, 0, len(pending))
+	for _, b := range pending {
+		updates = append(updates, b.member)
+		b.transmits++
+		if b.transmits >= limit {
+			delete(m.broadcasts, b.member.Name)
+		}
+	}
+	return updates
+}
+
+// nextProbeTarget walks the live members in a random order that is
+// reshuffled after every round, so each member is probed once per round.
+// Callers hold mu.
+func (m *Memberlist) nextProbeTarget() (Member, bool) {
+	for attempts := 0; attempts <= len(m.probeOrder); attempts++ {
+		if m.probeIdx >= len(m.probeOrder) {
+			m.probeOrder = m.probeOrder[:0]
+			for name, member := range m.members {
+				if name != m.config.Name && member.live() {
+					m.probeOrder = append(m.probeOrder, name)
+				}
+			}
+			rand.Shuffle(len(m.probeOrder), func(i, j int) {
+				m.probeOrder[i], m.probeOrder[j] = m.probeOrder[j], m.probeOrder[i]
+			})
+			m.probeIdx = 0
+			if len(m.probeOrder) == 0 {
+				return Member{}, false
+			}
+		}
+
+		member := m.members[m.probeOrder[m.probeIdx]]
+		m.probeIdx++
+		if member.live() {
+			return *member, true
+		}
+	}
+	return Member{}, false
+}
+
+// randomMembers picks up to k live members other than us and exclude.
+// Callers hold mu.
+func (m *Memberlist) randomMembers(k int, exclude string) []Member {
+	candidates := make([]Member, 0, len(m.members))
+	for name, member := range m.members {
+		if name != m.config.Name && name != exclude && member.live() {
+			candidates = append(candidates, *member)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	if len(candidates) > k {
+		candidates = candidates[:k]
+	}
+	return candidates
+}
+
+// emit queues a join or leave for eventLoop. Callers hold mu.
+func (m *Memberlist) emit(member Member, joined bool) {
+	m.eventMu.Lock()
+	m.events = append(m.events, event{member: member, joined: joined})
+	m.eventMu.Unlock()
+
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// eventLoop runs the callbacks outside mu, so a slow handoff does not hold
+// up the protocol and a callback may call Members
+func (m *Memberlist) eventLoop() {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-m.notify:
+		}
+
+		m.eventMu.Lock()
+		events := m.events
+		m.events = nil
+		m.eventMu.Unlock()
+
+		for _, e := range events {
+			switch {
+			case e.joined && m.config.OnJoin != nil:
+				m.config.OnJoin(e.member)
+			case !e.joined && m.config.OnLeave != nil:
+				m.config.OnLeave(e.member)
+			}
+		}
+	}
+}
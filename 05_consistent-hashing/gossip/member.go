@@ -0,0 +1,73 @@
+package gossip
+
+import "fmt"
+
+// State is what the cluster believes about a member
+type State int
+
+const (
+	// StateAlive members answer probes and are on the ring
+	StateAlive State = iota
+	// StateSuspect members missed a probe; they stay on the ring until the
+	// suspicion times out or they refute it
+	StateSuspect
+	// StateDead members were suspected for too long and are off the ring
+	StateDead
+	// StateLeft members announced they are leaving
+	StateLeft
+)
+
+var stateNames = []string{"alive", "suspect", "dead", "left"}
+
+func (s State) String() string {
+	if s < 0 || int(s) >= len(stateNames) {
+		return fmt.Sprintf("state(%d)", int(s))
+	}
+	return stateNames[s]
+}
+
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *State) UnmarshalText(text []byte) error {
+	for i, name := range stateNames {
+		if name == string(text) {
+			*s = State(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown member state %q", text)
+}
+
+// Member is one service instance as seen by the local instance
+type Member struct {
+	Name string `json:"name"`
+	Addr string `json:"addr"`
+	// Weight and VirtualNodes are what the member is added to the ring with
+	Weight       int `json:"weight"`
+	VirtualNodes int `json:"virtualNodes"`
+	// Incarnation is bumped only by the member itself, to refute a suspicion
+	// or to announce that it leaves
+	Incarnation uint64 `json:"incarnation"`
+	State       State  `json:"state"`
+}
+
+// live members own a part of the ring
+func (m Member) live() bool {
+	return m.State == StateAlive || m.State == StateSuspect
+}
+
+// overrides reports whether update u is newer than what we know in cur.
+// A higher incarnation always wins; at the same incarnation suspect beats
+// alive and dead or left beat both.
+func overrides(cur, u Member) bool {
+	switch u.State {
+	case StateAlive:
+		return u.Incarnation > cur.Incarnation
+	case StateSuspect:
+		return u.Incarnation > cur.Incarnation || (u.Incarnation == cur.Incarnation && cur.State == StateAlive)
+	default:
+		return u.Incarnation > cur.Incarnation || (u.Incarnation == cur.Incarnation && cur.live())
+	}
+}
@@ -0,0 +1,32 @@
+package gossip
+
+type msgType string
+
+const (
+	// ping asks Target to ack Seq
+	msgPing msgType = "ping"
+	// pingReq asks the receiver to ping Target on behalf of the sender
+	msgPingReq msgType = "ping-req"
+	msgAck     msgType = "ack"
+	// sync carries the sender's whole member list, the receiver merges it
+	// and answers with its own in a syncAck
+	msgSync    msgType = "sync"
+	msgSyncAck msgType = "sync-ack"
+)
+
+// message is the JSON datagram exchanged between instances. Every message
+// piggybacks the freshest membership updates in Updates.
+type message struct {
+	Type       msgType  `json:"type"`
+	Seq        uint32   `json:"seq,omitempty"`
+	Target     string   `json:"target,omitempty"`
+	TargetAddr string   `json:"targetAddr,omitempty"`
+	Members    []Member `json:"members,omitempty"`
+	Updates    []Member `json:"updates,omitempty"`
+}
+
+// broadcast is an update waiting to be piggybacked
+type broadcast struct {
+	member    Member
+	transmits int
+}
@@ -1,9 +1,21 @@
 package main
 
 import (
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
 	"github.com/AVVKavvk/consistent-hashing/algo"
 	"github.com/AVVKavvk/consistent-hashing/api"
 	_ "github.com/AVVKavvk/consistent-hashing/docs"
+	"github.com/AVVKavvk/consistent-hashing/gossip"
+	"github.com/AVVKavvk/consistent-hashing/redisClient"
+	"github.com/AVVKavvk/consistent-hashing/ringstore"
+	"github.com/AVVKavvk/consistent-hashing/service"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	echoSwagger "github.com/swaggo/echo-swagger"
@@ -16,8 +28,38 @@ import (
 // @BasePath /
 func main() {
 
+	// HASH_FUNCTION picks crc32 (default), fnv, murmur3 or xxhash for the ring
+	if name := os.Getenv("HASH_FUNCTION"); name != "" {
+		if err := algo.UseHashFunction(name); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	algo.InitHashRing()
 
+	// RING_STORE picks where the ring is saved: redis (default), file or none
+	if store := ringStore(); store != nil {
+		report, err := service.StartPersistenceService(store, time.Second)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("reconciled %d keys with Redis: added %v, removed %v, orphaned %d", report.Scanned, report.Added, report.Removed, report.Orphaned)
+	}
+
+	// HINT_REPLAY_INTERVAL is how often handed off writes are retried on
+	// their owner
+	replayInterval, err := time.ParseDuration(os.Getenv("HINT_REPLAY_INTERVAL"))
+	if err != nil {
+		replayInterval = 5 * time.Second
+	}
+	service.StartHintReplayService(replayInterval)
+
+	// GOSSIP_BIND turns on SWIM membership: this instance joins the ring as
+	// GOSSIP_NAME and finds the other instances through GOSSIP_SEEDS
+	if bind := os.Getenv("GOSSIP_BIND"); bind != "" {
+		startMembership(bind)
+	}
+
 	e := echo.New()
 
 	// Standard Logger Middleware
@@ -33,11 +75,80 @@ func main() {
 	{
 		serverApi.POST("", api.AddServer)
 		serverApi.GET("", api.GetAllServer)
+		serverApi.GET("/distribution", api.GetDistribution)
+		serverApi.POST("/reconcile", api.Reconcile)
+		serverApi.GET("/bounded-load", api.GetBoundedLoad)
+		serverApi.PUT("/bounded-load", api.SetBoundedLoad)
 		serverApi.DELETE("/:name", api.DeleServer)
+		serverApi.PUT("/:name/health", api.SetServerHealth)
 		serverApi.GET("/:name", api.GetServerInfo)
 	}
 
+	e.GET("/replication", api.GetReplication)
+	e.PUT("/replication", api.SetReplication)
+
+	e.GET("/members", api.GetMembers)
+
+	e.GET("/hints", api.GetHints)
+	e.POST("/hints/replay", api.ReplayHints)
+
 	e.GET("/docs/*", echoSwagger.WrapHandler)
 
-	e.Logger.Fatal(e.Start(":8080"))
+	// PORT lets several instances run on one host
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	e.Logger.Fatal(e.Start(":" + port))
+}
+
+func ringStore() ringstore.Store {
+	switch os.Getenv("RING_STORE") {
+	case "", "redis":
+		// instances sharing one Redis each need their own RING_STATE_KEY
+		key := os.Getenv("RING_STATE_KEY")
+		if key == "" {
+			key = ringstore.DefaultRedisKey
+		}
+		return ringstore.NewRedisStore(redisClient.GetRedisClient(), key)
+	case "file":
+		path := os.Getenv("RING_STATE_FILE")
+		if path == "" {
+			path = "ring-state.json"
+		}
+		return ringstore.NewFileStore(path)
+	case "none":
+		return nil
+	}
+	log.Fatalf("unknown RING_STORE %q, use redis, file or none", os.Getenv("RING_STORE"))
+	return nil
+}
+
+func startMembership(bind string) {
+	name := os.Getenv("GOSSIP_NAME")
+	if name == "" {
+		name, _ = os.Hostname()
+	}
+	config := gossip.DefaultConfig(name, bind)
+	config.AdvertiseAddr = os.Getenv("GOSSIP_ADVERTISE")
+	if weight, err := strconv.Atoi(os.Getenv("GOSSIP_WEIGHT")); err == nil {
+		config.Weight = weight
+	}
+
+	var seeds []string
+	if list := os.Getenv("GOSSIP_SEEDS"); list != "" {
+		seeds = strings.Split(list, ",")
+	}
+	if err := service.StartMembershipService(config, seeds); err != nil {
+		log.Fatal(err)
+	}
+
+	// leave on shutdown so the others hand our keys off right away
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-signals
+		service.LeaveMembershipService()
+		os.Exit(0)
+	}()
 }
@@ -0,0 +1,102 @@
+// Package membership turns gossip join and leave events into ring changes.
+// Every instance applies each change to its own ring, but only one of them
+// moves keys: the first live server clockwise from the member that joined
+// or left. Instances that share the ring and the member list make the same
+// pick without talking to each other.
+package membership
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/AVVKavvk/consistent-hashing/algo"
+	"github.com/AVVKavvk/consistent-hashing/gossip"
+)
+
+// Handlers holds what the callbacks do besides electing. The service moves
+// keys with AddServerService and DeleteServerService; tests and cmd/cluster
+// only record who was elected.
+type Handlers struct {
+	Ring *algo.HashRing
+	Self string
+
+	// Settle is how long after starting this instance sits out join
+	// elections. Right after joining it learns of every existing member as
+	// a join, and moving keys against its partial ring would misplace them.
+	Settle time.Duration
+
+	// Validate, if set, rejects members the ring must not take
+	Validate func(member gossip.Member) error
+	// Joined adds a member that joined to Ring and moves its keys onto it.
+	// Only the elected instance runs it.
+	Joined func(member gossip.Member)
+	// Left takes a member that died or left off Ring and hands its keys
+	// off. Only the elected instance runs it.
+	Left func(member gossip.Member)
+	// Changed, if set, runs after an instance that was not elected changed Ring
+	Changed func()
+
+	list    atomic.Pointer[gossip.Memberlist]
+	started time.Time
+}
+
+// NewMemberlist creates the memberlist for config with OnJoin and OnLeave
+// wired to h
+func (h *Handlers) NewMemberlist(config gossip.Config) (*gossip.Memberlist, error) {
+	config.OnJoin = h.OnJoin
+	config.OnLeave = h.OnLeave
+
+	h.started = time.Now()
+	list, err := gossip.New(config)
+	if err != nil {
+		return nil, err
+	}
+	h.list.Store(list)
+	return list, nil
+}
+
+// OnJoin adds member to the ring; the elected instance also moves its keys
+func (h *Handlers) OnJoin(member gossip.Member) {
+	if h.Validate != nil {
+		if err := h.Validate(member); err != nil {
+			log.Printf("not adding %s to the ring: %v", member.Name, err)
+			return
+		}
+	}
+	if time.Since(h.started) >= h.Settle && h.elected(member.Name) {
+		h.Joined(member)
+		return
+	}
+	h.Ring.AddNode(member.Name, member.VirtualNodes, member.Weight)
+	h.changed()
+}
+
+// OnLeave takes member off the ring; the elected instance also hands off
+// its keys
+func (h *Handlers) OnLeave(member gossip.Member) {
+	if h.elected(member.Name) {
+		h.Left(member)
+		return
+	}
+	h.Ring.DeleteNode(member.Name)
+	h.Ring.RecountOwners()
+	h.changed()
+}
+
+// elected reports whether this instance is the first live server clockwise
+// from name. Before the memberlist exists only our own join can fire, and
+// nobody moves keys for that.
+func (h *Handlers) elected(name string) bool {
+	list := h.list.Load()
+	if list == nil {
+		return false
+	}
+	return h.Ring.FindHandoffNode(name, list.Alive) == h.Self
+}
+
+func (h *Handlers) changed() {
+	if h.Changed != nil {
+		h.Changed()
+	}
+}
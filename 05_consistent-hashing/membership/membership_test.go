@@ -0,0 +1,244 @@
+package membership_test
+
+import (
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AVVKavvk/consistent-hashing/algo"
+	"github.com/AVVKavvk/consistent-hashing/gossip"
+	"github.com/AVVKavvk/consistent-hashing/membership"
+)
+
+// settle is how long a new instance sits out join elections in these tests
+const settle = 300 * time.Millisecond
+
+// instance is one in-process member with its own ring, fed by
+// membership.Handlers the way the service's ring is. Instead of moving keys
+// the elected instance records the member it was elected for.
+type instance struct {
+	name string
+	list *gossip.Memberlist
+	ring *algo.HashRing
+
+	mu       sync.Mutex
+	joins    []string
+	handoffs []string
+}
+
+func (in *instance) migratedJoins() []string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return append([]string(nil), in.joins...)
+}
+
+func (in *instance) handedOff() []string {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return append([]string(nil), in.handoffs...)
+}
+
+// newInstance starts one member on loopback without joining anyone
+func newInstance(t *testing.T, name string) *instance {
+	t.Helper()
+	in := &instance{name: name, ring: algo.NewHashRing()}
+
+	handlers := &membership.Handlers{
+		Ring:   in.ring,
+		Self:   name,
+		Settle: settle,
+		Joined: func(member gossip.Member) {
+			in.mu.Lock()
+			in.joins = append(in.joins, member.Name)
+			in.mu.Unlock()
+			in.ring.AddNode(member.Name, member.VirtualNodes, member.Weight)
+		},
+		Left: func(member gossip.Member) {
+			in.mu.Lock()
+			in.handoffs = append(in.handoffs, member.Name)
+			in.mu.Unlock()
+			in.ring.DeleteNode(member.Name)
+		},
+	}
+
+	config := gossip.DefaultConfig(name, "127.0.0.1:0")
+	config.ProbeInterval = 50 * time.Millisecond
+	config.ProbeTimeout = 25 * time.Millisecond
+	config.SuspicionTimeout = 250 * time.Millisecond
+	config.PushPullInterval = 500 * time.Millisecond
+	config.Logger = log.New(io.Discard, "", 0)
+
+	list, err := handlers.NewMemberlist(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	in.list = list
+	t.Cleanup(list.Shutdown)
+	return in
+}
+
+// startCluster starts n members, all joining through the first, and waits
+// until everyone sees everyone alive and has them on its ring
+func startCluster(t *testing.T, n int) []*instance {
+	t.Helper()
+
+	instances := make([]*instance, n)
+	for i := range instances {
+		instances[i] = newInstance(t, "node-"+strconv.Itoa(i))
+	}
+	for _, in := range instances[1:] {
+		if err := in.list.Join([]string{instances[0].list.Addr()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	eventually(t, "every member sees every other alive", func() bool {
+		for _, in := range instances {
+			for _, other := range instances {
+				if !in.list.Alive(other.name) || !in.ring.Snapshot().Has(other.name) {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return instances
+}
+
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting until %s", what)
+}
+
+func stateOf(list *gossip.Memberlist, name string) gossip.State {
+	for _, member := range list.Members() {
+		if member.Name == name {
+			return member.State
+		}
+	}
+	return -1
+}
+
+func TestJoinAddsEveryMemberToEveryRing(t *testing.T) {
+	instances := startCluster(t, 5)
+
+	eventually(t, "every ring has 5 nodes", func() bool {
+		for _, in := range instances {
+			if len(in.ring.Snapshot().Nodes()) != 5 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestCrashedMemberIsDeclaredDead(t *testing.T) {
+	instances := startCluster(t, 5)
+	crashed, survivors := instances[2], append(instances[:2:2], instances[3:]...)
+
+	crashed.list.Shutdown()
+
+	eventually(t, "the survivors declare the crashed member dead", func() bool {
+		for _, in := range survivors {
+			if stateOf(in.list, crashed.name) != gossip.StateDead {
+				return false
+			}
+		}
+		return true
+	})
+	eventually(t, "every survivor takes the member off its ring", func() bool {
+		for _, in := range survivors {
+			if in.ring.Snapshot().Has(crashed.name) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestLeaveSkipsSuspicion(t *testing.T) {
+	instances := startCluster(t, 4)
+	leaving, survivors := instances[1], append(instances[:1:1], instances[2:]...)
+
+	leaving.list.Leave()
+	leaving.list.Shutdown()
+
+	eventually(t, "the survivors see the member as left", func() bool {
+		for _, in := range survivors {
+			if stateOf(in.list, leaving.name) != gossip.StateLeft {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func TestOneMemberHandsOffADeadMembersKeys(t *testing.T) {
+	instances := startCluster(t, 6)
+	crashed, survivors := instances[3], append(instances[:3:3], instances[4:]...)
+
+	want := survivors[0].ring.FindHandoffNode(crashed.name, func(string) bool { return true })
+	crashed.list.Shutdown()
+
+	eventually(t, "every survivor takes the member off its ring", func() bool {
+		for _, in := range survivors {
+			if in.ring.Snapshot().Has(crashed.name) {
+				return false
+			}
+		}
+		return true
+	})
+
+	var by []string
+	for _, in := range survivors {
+		if handed := in.handedOff(); len(handed) > 0 {
+			by = append(by, in.name)
+		}
+	}
+	if len(by) != 1 || by[0] != want {
+		t.Errorf("handed off by %v, want only the successor %s", by, want)
+	}
+}
+
+// A member joining a settled cluster is migrated onto by exactly one
+// instance, its successor. The newcomer hears of every existing member as a
+// join too, but has not settled yet and moves nothing.
+func TestOneMemberMigratesAJoin(t *testing.T) {
+	instances := startCluster(t, 5)
+	time.Sleep(settle)
+
+	joiner := newInstance(t, "node-new")
+	want := instances[0].ring.FindHandoffNode(joiner.name, func(string) bool { return true })
+	if err := joiner.list.Join([]string{instances[0].list.Addr()}); err != nil {
+		t.Fatal(err)
+	}
+
+	all := append(instances, joiner)
+	eventually(t, "every ring has 6 nodes", func() bool {
+		for _, in := range all {
+			if len(in.ring.Snapshot().Nodes()) != 6 {
+				return false
+			}
+		}
+		return true
+	})
+
+	var by []string
+	for _, in := range all {
+		for _, member := range in.migratedJoins() {
+			by = append(by, in.name+"->"+member)
+		}
+	}
+	if len(by) != 1 || by[0] != want+"->"+joiner.name {
+		t.Errorf("joins migrated %v, want only %s->%s", by, want, joiner.name)
+	}
+}
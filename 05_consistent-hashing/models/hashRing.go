@@ -2,4 +2,99 @@ package models
 
 type CreateServer struct {
 	Name string `json:"name"`
+	// VirtualNodes per unit of weight, 0 uses the ring default
+	VirtualNodes int `json:"virtualNodes"`
+	// Weight scales the number of virtual nodes, 0 means 1
+	Weight int `json:"weight"`
+}
+
+// NodeShare is one row of the ring distribution report
+type NodeShare struct {
+	NodeName      string  `json:"nodeName"`
+	Weight        int     `json:"weight"`
+	VirtualNodes  int     `json:"virtualNodes"`
+	Keys          int     `json:"keys"`
+	Load          int     `json:"load"`
+	Share         float64 `json:"share"`
+	ExpectedShare float64 `json:"expectedShare"`
+}
+
+type BoundedLoad struct {
+	Enabled bool `json:"enabled"`
+	// Epsilon lets a node take up to (1+epsilon) times its fair share of keys
+	Epsilon float64 `json:"epsilon"`
+}
+
+type BoundedLoadStatus struct {
+	BoundedLoad
+	TotalKeys  int            `json:"totalKeys"`
+	Loads      map[string]int `json:"loads"`
+	Capacities map[string]int `json:"capacities"`
+	Spilled    int            `json:"spilled"`
+	// RingVersion is the ring membership the capacities were computed on
+	RingVersion uint64 `json:"ringVersion"`
+}
+
+// Distribution is the ring distribution report at one ring version
+type Distribution struct {
+	RingVersion uint64      `json:"ringVersion"`
+	Nodes       []NodeShare `json:"nodes"`
+}
+
+// Member is a service instance known to the gossip membership
+type Member struct {
+	Name         string `json:"name"`
+	Addr         string `json:"addr"`
+	Weight       int    `json:"weight"`
+	VirtualNodes int    `json:"virtualNodes"`
+	Incarnation  uint64 `json:"incarnation"`
+	// State is alive, suspect, dead or left
+	State string `json:"state"`
+}
+
+// RingState is what gets persisted to rebuild the ring after a restart
+type RingState struct {
+	Nodes   []CreateServer      `json:"nodes"`
+	Hashes  map[string][]int    `json:"hashes"`
+	UserIds map[string][]string `json:"userIds"`
+	// Spills maps the hash of a key that spilled under bounded loads to the
+	// server that owns it instead of its natural owner
+	Spills map[int]string `json:"spills,omitempty"`
+}
+
+// ReconcileReport tells how far the ring bookkeeping had drifted from Redis
+type ReconcileReport struct {
+	// Scanned user keys in Redis
+	Scanned int `json:"scanned"`
+	// Added and Removed count, per server, hashes that were in Redis but not
+	// in the bookkeeping and the other way round
+	Added   map[string]int `json:"added"`
+	Removed map[string]int `json:"removed"`
+	// Orphaned keys belong to servers that are not on the ring
+	Orphaned int `json:"orphaned"`
+}
+
+// Hint records a write that went to Holder because Owner was unreachable
+type Hint struct {
+	Owner     string `json:"owner"`
+	Holder    string `json:"holder"`
+	Hash      string `json:"hash"`
+	UserId    string `json:"userId"`
+	CreatedAt int64  `json:"createdAt"`
+}
+
+// HintStats are the hinted handoff metrics
+type HintStats struct {
+	Pending int64 `json:"pending"`
+	// PendingByOwner counts pending hints per unreachable owner
+	PendingByOwner map[string]int `json:"pendingByOwner"`
+	Stored         int64          `json:"stored"`
+	Replayed       int64          `json:"replayed"`
+	ReplayFailed   int64          `json:"replayFailed"`
+}
+
+type ServerHealth struct {
+	// Reachable false makes every Redis operation on the server fail, to
+	// try out hinted handoff
+	Reachable bool `json:"reachable"`
 }
@@ -4,4 +4,19 @@ type ResponseModel struct {
 	Users      []User `json:"users"`
 	ServerName string `json:"serverName"`
 	Hash       string `json:"hash"`
+	// Replicas that acknowledged the write or answered the read
+	Replicas []string `json:"replicas,omitempty"`
+	// HintedTo maps each unreachable replica to the server holding its copy
+	HintedTo map[string]string `json:"hintedTo,omitempty"`
+	// RingVersion is the ring membership the replicas were picked from
+	RingVersion uint64 `json:"ringVersion"`
+}
+
+type ReplicationConfig struct {
+	// N copies of every key, on the next N distinct servers clockwise
+	N int `json:"n"`
+	// R replicas must answer a read
+	R int `json:"r"`
+	// W replicas must acknowledge a write
+	W int `json:"w"`
 }
@@ -5,4 +5,6 @@ type User struct {
 	Name  string `json:"name"`
 	Age   int    `json:"age"`
 	Email string `json:"email"`
+	// Version is set on every write; replicas with a lower version are stale
+	Version int64 `json:"version"`
 }
@@ -0,0 +1,48 @@
+package placement
+
+import (
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/spaolacci/murmur3"
+)
+
+// HashFunc maps bytes to a 64 bit hash
+type HashFunc func(data []byte) uint64
+
+var hashFuncs = map[string]HashFunc{
+	"crc32": func(data []byte) uint64 {
+		return uint64(crc32.ChecksumIEEE(data))
+	},
+	"fnv": func(data []byte) uint64 {
+		h := fnv.New64a()
+		_, _ = h.Write(data)
+		return h.Sum64()
+	},
+	"murmur3": func(data []byte) uint64 {
+		return murmur3.Sum64(data)
+	},
+	"xxhash": xxhash.Sum64,
+}
+
+// GetHashFunc returns the hash function registered under name
+func GetHashFunc(name string) (HashFunc, error) {
+	h, ok := hashFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash function %q, use one of %v", name, HashFuncNames())
+	}
+	return h, nil
+}
+
+// HashFuncNames lists the registered hash functions
+func HashFuncNames() []string {
+	names := make([]string, 0, len(hashFuncs))
+	for name := range hashFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
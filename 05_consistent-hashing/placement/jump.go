@@ -0,0 +1,53 @@
+package placement
+
+// Jump is Jump Consistent Hash (Lamping and Veach). It needs no memory
+// beyond the node list, but buckets are numbered: only removing the last
+// added node is cheap, removing any other renumbers the nodes after it.
+type Jump struct {
+	hash  HashFunc
+	nodes []string // bucket i is nodes[i], in the order they were added
+}
+
+func NewJump(hash HashFunc) *Jump {
+	return &Jump{hash: hash}
+}
+
+func (j *Jump) Name() string { return "jump" }
+
+func (j *Jump) Add(node string) {
+	for _, n := range j.nodes {
+		if n == node {
+			return
+		}
+	}
+	j.nodes = append(j.nodes, node)
+}
+
+func (j *Jump) Remove(node string) {
+	for i, n := range j.nodes {
+		if n == node {
+			j.nodes = append(j.nodes[:i], j.nodes[i+1:]...)
+			return
+		}
+	}
+}
+
+func (j *Jump) Locate(key string) string {
+	if len(j.nodes) == 0 {
+		return ""
+	}
+	return j.nodes[jumpHash(j.hash([]byte(key)), len(j.nodes))]
+}
+
+func (j *Jump) Nodes() []string { return append([]string(nil), j.nodes...) }
+
+// jumpHash returns a bucket in [0, buckets)
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+	return int(b)
+}
@@ -0,0 +1,78 @@
+package placement
+
+// MaglevTableSize is the lookup table size, a prime well above 100x the node count
+const MaglevTableSize = 65537
+
+// Maglev is Google's Maglev hashing: each node walks its own permutation of
+// a fixed size table and claims slots in turn, so every node gets almost
+// exactly the same number of slots. Lookups are one table read.
+type Maglev struct {
+	hash    HashFunc
+	size    uint64
+	members nodeList
+	table   []int // slot to index in members, -1 when empty
+}
+
+func NewMaglev(hash HashFunc, size uint64) *Maglev {
+	return &Maglev{hash: hash, size: size}
+}
+
+func (m *Maglev) Name() string { return "maglev" }
+
+func (m *Maglev) Add(node string) {
+	if m.members.add(node) {
+		m.populate()
+	}
+}
+
+func (m *Maglev) Remove(node string) {
+	if m.members.remove(node) {
+		m.populate()
+	}
+}
+
+func (m *Maglev) Locate(key string) string {
+	if len(m.members) == 0 {
+		return ""
+	}
+	return m.members[m.table[m.hash([]byte(key))%m.size]]
+}
+
+func (m *Maglev) Nodes() []string { return m.members.nodes() }
+
+// populate fills the table following the permutation of each node in turn
+func (m *Maglev) populate() {
+	n := len(m.members)
+	m.table = make([]int, m.size)
+	for i := range m.table {
+		m.table[i] = -1
+	}
+	if n == 0 {
+		return
+	}
+
+	offsets := make([]uint64, n)
+	skips := make([]uint64, n)
+	next := make([]uint64, n)
+	for i, node := range m.members {
+		offsets[i] = m.hash([]byte(node)) % m.size
+		skips[i] = m.hash([]byte(node+"#skip"))%(m.size-1) + 1
+	}
+
+	filled := uint64(0)
+	for {
+		for i := 0; i < n; i++ {
+			slot := (offsets[i] + next[i]*skips[i]) % m.size
+			for m.table[slot] >= 0 {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % m.size
+			}
+			m.table[slot] = i
+			next[i]++
+			filled++
+			if filled == m.size {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Package placement holds interchangeable algorithms that map a key to one
+// of a set of nodes. They are used by cmd/simulate to compare key movement
+// and load balance; the service itself keeps using algo.HashRing.
+package placement
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Placement decides which node owns a key
+type Placement interface {
+	// Name identifies the algorithm in reports
+	Name() string
+	// Add puts a node into the set
+	Add(node string)
+	// Remove takes a node out of the set
+	Remove(node string)
+	// Locate returns the node owning key, or "" if there are no nodes
+	Locate(key string) string
+	// Nodes returns the current nodes
+	Nodes() []string
+}
+
+// New builds the algorithm called name: ring, jump, rendezvous, maglev or bounded
+func New(name string, hash HashFunc, virtualNodes int, epsilon float64) (Placement, error) {
+	switch name {
+	case "ring":
+		return NewRing(hash, virtualNodes), nil
+	case "jump":
+		return NewJump(hash), nil
+	case "rendezvous":
+		return NewRendezvous(hash), nil
+	case "maglev":
+		return NewMaglev(hash, MaglevTableSize), nil
+	case "bounded":
+		return NewBounded(hash, virtualNodes, epsilon), nil
+	}
+	return nil, fmt.Errorf("unknown placement %q, use one of %v", name, Names)
+}
+
+// Names lists the algorithms New understands
+var Names = []string{"ring", "jump", "rendezvous", "maglev", "bounded"}
+
+// nodeList is the sorted node bookkeeping shared by the algorithms that do
+// not keep their own structure
+type nodeList []string
+
+func (l *nodeList) add(node string) bool {
+	idx := sort.SearchStrings(*l, node)
+	if idx < len(*l) && (*l)[idx] == node {
+		return false
+	}
+	*l = append(*l, "")
+	copy((*l)[idx+1:], (*l)[idx:])
+	(*l)[idx] = node
+	return true
+}
+
+func (l *nodeList) remove(node string) bool {
+	idx := sort.SearchStrings(*l, node)
+	if idx == len(*l) || (*l)[idx] != node {
+		return false
+	}
+	*l = append((*l)[:idx], (*l)[idx+1:]...)
+	return true
+}
+
+func (l nodeList) nodes() []string {
+	return append([]string(nil), l...)
+}
@@ -0,0 +1,33 @@
+package placement
+
+// Rendezvous is highest random weight hashing: every node scores the key and
+// the highest score wins. Lookups cost O(nodes) but removing a node only
+// moves the keys it owned.
+type Rendezvous struct {
+	hash    HashFunc
+	members nodeList
+}
+
+func NewRendezvous(hash HashFunc) *Rendezvous {
+	return &Rendezvous{hash: hash}
+}
+
+func (r *Rendezvous) Name() string { return "rendezvous" }
+
+func (r *Rendezvous) Add(node string) { r.members.add(node) }
+
+func (r *Rendezvous) Remove(node string) { r.members.remove(node) }
+
+func (r *Rendezvous) Locate(key string) string {
+	var best string
+	var bestScore uint64
+	for _, node := range r.members {
+		score := r.hash([]byte(node + "\x00" + key))
+		if best == "" || score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	return best
+}
+
+func (r *Rendezvous) Nodes() []string { return r.members.nodes() }
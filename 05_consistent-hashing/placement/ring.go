@@ -0,0 +1,132 @@
+package placement
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Ring is classic consistent hashing with virtual nodes
+type Ring struct {
+	hash         HashFunc
+	virtualNodes int
+	points       []uint64          // sorted virtual node hashes
+	owners       map[uint64]string // virtual node hash to node
+	members      nodeList
+}
+
+func NewRing(hash HashFunc, virtualNodes int) *Ring {
+	if virtualNodes < 1 {
+		virtualNodes = 1
+	}
+	return &Ring{hash: hash, virtualNodes: virtualNodes, owners: make(map[uint64]string)}
+}
+
+func (r *Ring) Name() string { return "ring" }
+
+func (r *Ring) Add(node string) {
+	if !r.members.add(node) {
+		return
+	}
+	for i := 0; i < r.virtualNodes; i++ {
+		point := r.hash([]byte(node + "#" + strconv.Itoa(i)))
+		if _, taken := r.owners[point]; taken {
+			continue
+		}
+		r.owners[point] = node
+		r.points = append(r.points, point)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+}
+
+func (r *Ring) Remove(node string) {
+	if !r.members.remove(node) {
+		return
+	}
+	points := r.points[:0]
+	for _, point := range r.points {
+		if r.owners[point] == node {
+			delete(r.owners, point)
+			continue
+		}
+		points = append(points, point)
+	}
+	r.points = points
+}
+
+func (r *Ring) Locate(key string) string {
+	if len(r.points) == 0 {
+		return ""
+	}
+	return r.owners[r.points[r.search(r.hash([]byte(key)))]]
+}
+
+func (r *Ring) Nodes() []string { return r.members.nodes() }
+
+// search returns the index of the first point >= hash, wrapping to 0
+func (r *Ring) search(hash uint64) int {
+	idx := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if idx == len(r.points) {
+		idx = 0
+	}
+	return idx
+}
+
+// Bounded is consistent hashing with bounded loads (Mirrokni, Thorup and
+// Zadimoghaddam): no node takes more than ceil((1+epsilon) * average) keys,
+// a key whose owner is full walks clockwise to the next node with room.
+// Placement depends on arrival order, so membership changes reassign
+// every key on its next Locate.
+type Bounded struct {
+	*Ring
+	epsilon  float64
+	loads    map[string]int
+	assigned map[string]string
+}
+
+func NewBounded(hash HashFunc, virtualNodes int, epsilon float64) *Bounded {
+	return &Bounded{
+		Ring:     NewRing(hash, virtualNodes),
+		epsilon:  epsilon,
+		loads:    make(map[string]int),
+		assigned: make(map[string]string),
+	}
+}
+
+func (b *Bounded) Name() string { return "bounded" }
+
+func (b *Bounded) Add(node string) {
+	b.Ring.Add(node)
+	b.reset()
+}
+
+func (b *Bounded) Remove(node string) {
+	b.Ring.Remove(node)
+	b.reset()
+}
+
+func (b *Bounded) Locate(key string) string {
+	if node, ok := b.assigned[key]; ok {
+		return node
+	}
+	if len(b.points) == 0 {
+		return ""
+	}
+
+	capacity := int(math.Ceil((1 + b.epsilon) * float64(len(b.assigned)+1) / float64(len(b.members))))
+	start := b.search(b.hash([]byte(key)))
+	for step := 0; step < len(b.points); step++ {
+		node := b.owners[b.points[(start+step)%len(b.points)]]
+		if b.loads[node] < capacity {
+			b.loads[node]++
+			b.assigned[key] = node
+			return node
+		}
+	}
+	return ""
+}
+
+func (b *Bounded) reset() {
+	b.loads = make(map[string]int)
+	b.assigned = make(map[string]string)
+}
@@ -0,0 +1,34 @@
+package redisClient
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrNodeUnreachable is returned for every operation on a server marked
+// unreachable. All servers share one Redis here, so this is how an outage
+// of a single server is simulated.
+var ErrNodeUnreachable = errors.New("server is unreachable")
+
+var unreachable sync.Map
+
+// SetNodeReachable marks a server as reachable or not
+func SetNodeReachable(nodeName string, reachable bool) {
+	if reachable {
+		unreachable.Delete(nodeName)
+		return
+	}
+	unreachable.Store(nodeName, true)
+}
+
+func IsNodeReachable(nodeName string) bool {
+	_, down := unreachable.Load(nodeName)
+	return !down
+}
+
+func checkNode(nodeName string) error {
+	if !IsNodeReachable(nodeName) {
+		return ErrNodeUnreachable
+	}
+	return nil
+}
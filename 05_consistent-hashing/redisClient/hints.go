@@ -0,0 +1,78 @@
+package redisClient
+
+import (
+	"encoding/json"
+
+	"github.com/go-redis/redis"
+
+	"github.com/AVVKavvk/consistent-hashing/models"
+)
+
+// hintsKey is a Redis hash of owner:hash to the JSON hint. It has no ':'
+// so the reconciliation scan skips it.
+const hintsKey = "hints"
+
+// deleteHintScript removes a hint only if it is still the one that was
+// replayed, so a newer hint written meanwhile is kept
+var deleteHintScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// StoreHint records that hint.Holder keeps the copy meant for hint.Owner
+func StoreHint(hint *models.Hint) error {
+	client := GetRedisClient()
+
+	hintJSON, err := json.Marshal(hint)
+	if err != nil {
+		return err
+	}
+	return client.HSet(hintsKey, hint.Owner+":"+hint.Hash, hintJSON).Err()
+}
+
+func GetHint(owner string, hash string) (*models.Hint, error) {
+	client := GetRedisClient()
+
+	val, err := client.HGet(hintsKey, owner+":"+hash).Result()
+	if err != nil {
+		return nil, err
+	}
+	var hint models.Hint
+	if err := json.Unmarshal([]byte(val), &hint); err != nil {
+		return nil, err
+	}
+	return &hint, nil
+}
+
+func GetAllHints() ([]models.Hint, error) {
+	client := GetRedisClient()
+
+	vals, err := client.HGetAll(hintsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	hints := make([]models.Hint, 0, len(vals))
+	for _, val := range vals {
+		var hint models.Hint
+		if err := json.Unmarshal([]byte(val), &hint); err != nil {
+			continue
+		}
+		hints = append(hints, hint)
+	}
+	return hints, nil
+}
+
+// DeleteHint removes hint if it has not been replaced since it was read and
+// reports whether it did
+func DeleteHint(hint *models.Hint) (bool, error) {
+	client := GetRedisClient()
+
+	hintJSON, err := json.Marshal(hint)
+	if err != nil {
+		return false, err
+	}
+	deleted, err := deleteHintScript.Run(client, []string{hintsKey}, hint.Owner+":"+hint.Hash, hintJSON).Int()
+	return deleted == 1, err
+}
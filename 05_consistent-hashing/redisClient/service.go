@@ -2,12 +2,23 @@ package redisClient
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/go-redis/redis"
 
 	"github.com/AVVKavvk/consistent-hashing/models"
 )
 
 // StoreUserDataWithHashToRedisWithNode
 func StoreUserDataWithHashToRedisWithNode(nodeName string, hash string, user *models.User) error {
+	if err := checkNode(nodeName); err != nil {
+		return err
+	}
 	client := GetRedisClient()
 
 	key := nodeName + ":" + hash
@@ -21,7 +32,41 @@ func StoreUserDataWithHashToRedisWithNode(nodeName string, hash string, user *mo
 
 }
 
+// storeIfNewerScript writes ARGV[1] unless the stored copy already has a
+// version >= ARGV[2], so a read-repair never clobbers a concurrent write
+var storeIfNewerScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if cur then
+	local ok, decoded = pcall(cjson.decode, cur)
+	if ok and decoded.version and decoded.version >= tonumber(ARGV[2]) then
+		return 0
+	end
+end
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+// StoreUserDataWithHashToRedisWithNodeIfNewer is used by read-repair
+func StoreUserDataWithHashToRedisWithNodeIfNewer(nodeName string, hash string, user *models.User) error {
+	if err := checkNode(nodeName); err != nil {
+		return err
+	}
+	client := GetRedisClient()
+
+	key := nodeName + ":" + hash
+
+	userJSON, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	return storeIfNewerScript.Run(client, []string{key}, userJSON, strconv.FormatInt(user.Version, 10)).Err()
+}
+
 func GetUserDataWithHashFromRedisWithNode(nodeName string, hash string) (*models.User, error) {
+	if err := checkNode(nodeName); err != nil {
+		return nil, err
+	}
 	client := GetRedisClient()
 	key := nodeName + ":" + hash
 	var user models.User
@@ -37,6 +82,9 @@ func GetUserDataWithHashFromRedisWithNode(nodeName string, hash string) (*models
 }
 
 func DeleteUserDataWithHashFromRedisWithNode(nodeName string, hash string) error {
+	if err := checkNode(nodeName); err != nil {
+		return err
+	}
 	client := GetRedisClient()
 	key := nodeName + ":" + hash
 	err := client.Del(key).Err()
@@ -45,3 +93,65 @@ func DeleteUserDataWithHashFromRedisWithNode(nodeName string, hash string) error
 	}
 	return nil
 }
+
+// ScanUserData walks every serverName:hash key and calls fn with the decoded
+// user. Keys that are not user data are skipped. It returns how many user
+// keys were found.
+func ScanUserData(fn func(nodeName string, hash int, user *models.User)) (int, error) {
+	client := GetRedisClient()
+
+	scanned := 0
+	var cursor uint64
+	for {
+		keys, next, err := client.Scan(cursor, "*:*", 500).Result()
+		if err != nil {
+			return scanned, err
+		}
+
+		// the key itself only tells the server and the hash, the user id
+		// comes from the value
+		pipe := client.Pipeline()
+		gets := make([]*redis.StringCmd, len(keys))
+		for i, key := range keys {
+			gets[i] = pipe.Get(key)
+		}
+		if len(keys) > 0 {
+			// Exec reports the first failed GET, which may be one key of the
+			// wrong type, so only a lost connection fails the whole scan
+			if _, err := pipe.Exec(); isConnError(err) {
+				return scanned, err
+			}
+		}
+
+		for i, key := range keys {
+			sep := strings.LastIndexByte(key, ':')
+			hash, err := strconv.Atoi(key[sep+1:])
+			if err != nil {
+				continue
+			}
+			if err := gets[i].Err(); err != nil {
+				if err != redis.Nil {
+					log.Printf("scan: skipping %s: %v", key, err)
+				}
+				continue
+			}
+			var user models.User
+			if err := json.Unmarshal([]byte(gets[i].Val()), &user); err != nil {
+				log.Printf("scan: skipping %s: %v", key, err)
+				continue
+			}
+			scanned++
+			fn(key[:sep], hash, &user)
+		}
+
+		if next == 0 {
+			return scanned, nil
+		}
+		cursor = next
+	}
+}
+
+func isConnError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.EOF) || errors.As(err, &netErr)
+}
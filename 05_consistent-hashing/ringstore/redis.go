@@ -0,0 +1,46 @@
+package ringstore
+
+import (
+	"encoding/json"
+
+	"github.com/go-redis/redis"
+
+	"github.com/AVVKavvk/consistent-hashing/models"
+)
+
+// DefaultRedisKey has no ':' so the reconciliation scan of user keys
+// (server:hash) never mistakes it for user data
+const DefaultRedisKey = "ring-state"
+
+// RedisStore keeps the state as one JSON value, next to the user data
+type RedisStore struct {
+	client redis.Cmdable
+	key    string
+}
+
+func NewRedisStore(client redis.Cmdable, key string) *RedisStore {
+	return &RedisStore{client: client, key: key}
+}
+
+func (s *RedisStore) Save(state models.RingState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(s.key, data, 0).Err()
+}
+
+func (s *RedisStore) Load() (*models.RingState, error) {
+	data, err := s.client.Get(s.key).Bytes()
+	if err == redis.Nil {
+		return nil, ErrNoState
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state models.RingState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,17 @@
+// Package ringstore persists the hash ring membership and key bookkeeping,
+// so a restarted instance comes back with the ring it had
+package ringstore
+
+import (
+	"errors"
+
+	"github.com/AVVKavvk/consistent-hashing/models"
+)
+
+// ErrNoState is returned by Load when nothing was saved yet
+var ErrNoState = errors.New("no saved ring state")
+
+type Store interface {
+	Save(state models.RingState) error
+	Load() (*models.RingState, error)
+}
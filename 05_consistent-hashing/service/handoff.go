@@ -0,0 +1,185 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/AVVKavvk/consistent-hashing/algo"
+	"github.com/AVVKavvk/consistent-hashing/models"
+	"github.com/AVVKavvk/consistent-hashing/redisClient"
+	"github.com/go-redis/redis"
+)
+
+var ErrNoFallback = errors.New("no reachable server to hand the write off to")
+
+// hinted handoff metrics, pending hints are counted in Redis
+var (
+	hintsStored       atomic.Int64
+	hintsReplayed     atomic.Int64
+	hintsReplayFailed atomic.Int64
+)
+
+// fallbackNodes lists, in ring order, the servers after the preference list
+// that can take a write for an unreachable replica
+func fallbackNodes(hash int, replicas []string) chan string {
+	all, _ := algo.GetHashRing().GetPreferenceList(hash, math.MaxInt)
+	fallbacks := make(chan string, len(all))
+	for _, nodeName := range all {
+		if !containsString(replicas, nodeName) {
+			fallbacks <- nodeName
+		}
+	}
+	close(fallbacks)
+	return fallbacks
+}
+
+// handOff writes the copy meant for owner to the next fallback that takes
+// it and leaves a hint, so ReplayHintsService can move it to owner later.
+// fallbacks is shared by all replicas of a write, so each failed replica
+// gets a different holder.
+func handOff(owner string, hash int, user *models.User, fallbacks chan string) (string, error) {
+	hr := algo.GetHashRing()
+	hashStr := strconv.Itoa(hash)
+
+	for holder := range fallbacks {
+		if err := redisClient.StoreUserDataWithHashToRedisWithNodeIfNewer(holder, hashStr, user); err != nil {
+			continue
+		}
+		hint := &models.Hint{Owner: owner, Holder: holder, Hash: hashStr, UserId: user.ID, CreatedAt: time.Now().UnixMilli()}
+		if err := redisClient.StoreHint(hint); err != nil {
+			return "", err
+		}
+		hr.AddHashToNode(holder, hash)
+		hr.AddUserIdToNode(holder, user.ID)
+		hintsStored.Add(1)
+		return holder, nil
+	}
+	return "", ErrNoFallback
+}
+
+// readHinted reads owner's copy of hash from the server holding it for owner
+func readHinted(owner string, hashStr string) (*models.User, error) {
+	hint, err := redisClient.GetHint(owner, hashStr)
+	if err != nil {
+		return nil, err
+	}
+	return redisClient.GetUserDataWithHashFromRedisWithNode(hint.Holder, hashStr)
+}
+
+// hintedCopies returns the holder:hash of every pending hint, so a
+// rebalance does not drop a copy that still has to reach its owner
+func hintedCopies() map[string]bool {
+	hints, err := redisClient.GetAllHints()
+	if err != nil {
+		return nil
+	}
+	copies := make(map[string]bool, len(hints))
+	for _, hint := range hints {
+		copies[hint.Holder+":"+hint.Hash] = true
+	}
+	return copies
+}
+
+// StartHintReplayService replays pending hints every interval
+func StartHintReplayService(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if _, err := ReplayHintsService(); err != nil {
+				log.Printf("replaying hints: %v", err)
+			}
+		}
+	}()
+}
+
+// ReplayHintsService moves every hinted copy whose owner is reachable again
+// to the owner and returns the stats afterwards
+func ReplayHintsService() (models.HintStats, error) {
+	hints, err := redisClient.GetAllHints()
+	if err != nil {
+		return models.HintStats{}, err
+	}
+	for i := range hints {
+		replayHint(&hints[i])
+	}
+	return GetHintStatsService()
+}
+
+func replayHint(hint *models.Hint) {
+	hr := algo.GetHashRing()
+	hash, err := strconv.Atoi(hint.Hash)
+	if err != nil {
+		return
+	}
+
+	if !hr.Snapshot().Has(hint.Owner) {
+		// the owner left the ring; the rebalance that followed placed the
+		// key from the holder's copy
+		redisClient.DeleteHint(hint)
+		return
+	}
+
+	user, err := redisClient.GetUserDataWithHashFromRedisWithNode(hint.Holder, hint.Hash)
+	if err == redis.Nil {
+		redisClient.DeleteHint(hint)
+		return
+	}
+	if err != nil {
+		hintsReplayFailed.Add(1)
+		return
+	}
+
+	// still unreachable: keep the hint for the next round
+	if err := redisClient.StoreUserDataWithHashToRedisWithNodeIfNewer(hint.Owner, hint.Hash, user); err != nil {
+		if !errors.Is(err, redisClient.ErrNodeUnreachable) {
+			hintsReplayFailed.Add(1)
+		}
+		return
+	}
+	hr.AddHashToNode(hint.Owner, hash)
+	hr.AddUserIdToNode(hint.Owner, user.ID)
+
+	deleted, err := redisClient.DeleteHint(hint)
+	if err != nil || !deleted {
+		// a newer write was handed off meanwhile, its hint replays next round
+		return
+	}
+	hintsReplayed.Add(1)
+	markDirty()
+
+	// the holder keeps its copy only if it is a replica in its own right
+	want, _ := hr.GetPreferenceList(hash, GetReplicationService().N)
+	if !containsString(want, hint.Holder) {
+		if err := redisClient.DeleteUserDataWithHashFromRedisWithNode(hint.Holder, hint.Hash); err == nil {
+			hr.RemoveHashFromNode(hint.Holder, hash, user.ID)
+		}
+	}
+}
+
+func GetHintStatsService() (models.HintStats, error) {
+	hints, err := redisClient.GetAllHints()
+	if err != nil {
+		return models.HintStats{}, err
+	}
+	byOwner := make(map[string]int)
+	for _, hint := range hints {
+		byOwner[hint.Owner]++
+	}
+	return models.HintStats{
+		Pending:        int64(len(hints)),
+		PendingByOwner: byOwner,
+		Stored:         hintsStored.Load(),
+		Replayed:       hintsReplayed.Load(),
+		ReplayFailed:   hintsReplayFailed.Load(),
+	}, nil
+}
+
+func SetServerHealthService(name string, health *models.ServerHealth) models.ServerHealth {
+	redisClient.SetNodeReachable(name, health.Reachable)
+	return models.ServerHealth{Reachable: redisClient.IsNodeReachable(name)}
+}
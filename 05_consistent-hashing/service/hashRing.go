@@ -1,21 +1,71 @@
 package service
 
 import (
-	"strconv"
-	"sync"
+	"errors"
+	"fmt"
 
 	"github.com/AVVKavvk/consistent-hashing/algo"
 	"github.com/AVVKavvk/consistent-hashing/models"
-	"github.com/AVVKavvk/consistent-hashing/redisClient"
 )
 
-var wg sync.WaitGroup
+// Limits on a new server. Every ring point costs memory and lookup time, so
+// a client must not be able to ask for millions of them.
+const (
+	MaxVirtualNodes = 1000
+	MaxWeight       = 100
+	MaxRingPoints   = 10000 // virtualNodes * weight
+)
+
+var (
+	ErrInvalidEpsilon = errors.New("epsilon must be >= 0")
+	ErrInvalidServer  = errors.New("invalid server")
+)
+
+// validateServer rejects a server the ring cannot take. 0 virtual nodes or
+// weight mean the defaults, as in algo.AddNode.
+func validateServer(server *models.CreateServer) error {
+	virtualNodes, weight := server.VirtualNodes, server.Weight
+	if virtualNodes == 0 {
+		virtualNodes = algo.DefaultVirtualNodes
+	}
+	if weight == 0 {
+		weight = 1
+	}
+	switch {
+	case server.Name == "":
+		return fmt.Errorf("%w: name is required", ErrInvalidServer)
+	case virtualNodes < 0 || virtualNodes > MaxVirtualNodes:
+		return fmt.Errorf("%w: virtualNodes must be between 0 and %d", ErrInvalidServer, MaxVirtualNodes)
+	case weight < 0 || weight > MaxWeight:
+		return fmt.Errorf("%w: weight must be between 0 and %d", ErrInvalidServer, MaxWeight)
+	case virtualNodes*weight > MaxRingPoints:
+		return fmt.Errorf("%w: virtualNodes * weight must be at most %d", ErrInvalidServer, MaxRingPoints)
+	}
+	return nil
+}
 
-func AddServerService(server *models.CreateServer) map[string]interface{} {
+func AddServerService(server *models.CreateServer) (map[string]interface{}, error) {
+	if err := validateServer(server); err != nil {
+		return nil, err
+	}
 	hr := algo.GetHashRing()
-	hr.AddNode(server.Name)
+	holders := hr.GetKeyHolders()
+
+	// Mark the node as joining before it owns anything, so a read that
+	// misses on it during the migration retries the previous owner
+	hr.StartJoin(server.Name)
+	hr.AddNode(server.Name, server.VirtualNodes, server.Weight)
+
+	// keys whose preference list now includes the new node are copied to
+	// it, and the server that fell out of each list drops its copy
+	copied, dropped := rebalance(holders)
+	hr.FinishJoin(server.Name)
+	saveRingState()
+
 	result := hr.GetNodeInfo(server.Name)
-	return result
+	result["copied"] = copied
+	result["movedFrom"] = dropped
+	return result, nil
 }
 
 func GetAllServerInfoService() []map[string]interface{} {
@@ -28,52 +78,38 @@ func GetInfoOfServerByName(serverName string) map[string]interface{} {
 	return hr.GetNodeInfo(serverName)
 }
 
+func GetDistributionService() models.Distribution {
+	hr := algo.GetHashRing()
+	return hr.GetDistribution()
+}
+
 func DeleteServerService(name string) map[string]interface{} {
 	hr := algo.GetHashRing()
-	nextServerName := hr.FindTheNextNodeForNode(name)
-	allHashesForThisNode := hr.GetHashesForNode(name)
+	holders := hr.GetKeyHolders()
 
-	wg.Add(len(allHashesForThisNode))
+	// Take the node off the ring first: with virtual nodes every key can
+	// land on a different successor, so the new replicas are looked up per key
+	ringVersion := hr.DeleteNode(name)
 
-	for _, hash := range allHashesForThisNode {
-		go deleteHelper(name, hash, nextServerName, &wg)
-	}
-	wg.Wait()
-	hr.DeleteNode(name)
+	movedTo, _ := rebalance(holders)
+	saveRingState()
 	return map[string]interface{}{
-		"message":   "done",
-		"newServer": nextServerName,
+		"message":     "done",
+		"movedTo":     movedTo,
+		"ringVersion": ringVersion,
 	}
-
 }
 
-func deleteHelper(name string, hash int, nextServerName string, wg *sync.WaitGroup) error {
-	defer wg.Done()
-
-	defer func() {
-		// Recover from panic
-		if r := recover(); r != nil {
-			// Print stack trace
-			panic(r)
-		}
-	}()
-
+func GetBoundedLoadService() models.BoundedLoadStatus {
 	hr := algo.GetHashRing()
-	userData, err := redisClient.GetUserDataWithHashFromRedisWithNode(name, strconv.Itoa(hash))
-	if err != nil {
-		return err
-	}
-
-	err = redisClient.StoreUserDataWithHashToRedisWithNode(nextServerName, strconv.Itoa(hash), userData)
-
-	if err != nil {
-		return err
-	}
-	hr.AddHashToNode(nextServerName, hash)
+	return hr.GetBoundedLoad()
+}
 
-	err = redisClient.DeleteUserDataWithHashFromRedisWithNode(name, strconv.Itoa(hash))
-	if err != nil {
-		return err
+func SetBoundedLoadService(config *models.BoundedLoad) (models.BoundedLoadStatus, error) {
+	hr := algo.GetHashRing()
+	if config.Epsilon < 0 {
+		return hr.GetBoundedLoad(), ErrInvalidEpsilon
 	}
-	return nil
+	hr.SetBoundedLoad(*config)
+	return hr.GetBoundedLoad(), nil
 }
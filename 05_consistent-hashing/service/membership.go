@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"log"
+
+	"github.com/AVVKavvk/consistent-hashing/algo"
+	"github.com/AVVKavvk/consistent-hashing/gossip"
+	"github.com/AVVKavvk/consistent-hashing/membership"
+	"github.com/AVVKavvk/consistent-hashing/models"
+)
+
+var ErrMembershipDisabled = errors.New("gossip membership is not enabled, set GOSSIP_BIND")
+
+var memberlist *gossip.Memberlist
+
+// StartMembershipService joins this instance to the gossip cluster. Every
+// instance adds members that become alive to its ring and takes members that
+// die or leave off it, but for each change only one instance, elected by
+// membership.Handlers, moves keys: AddServerService for a join and
+// DeleteServerService for a departure, exactly as for the manual
+// POST /servers and DELETE /servers/:name.
+func StartMembershipService(config gossip.Config, seeds []string) error {
+	handlers := &membership.Handlers{
+		Ring: algo.GetHashRing(),
+		Self: config.Name,
+		// Join syncs with a seed right away, and by the first periodic
+		// push/pull we have certainly heard of every member
+		Settle: config.PushPullInterval,
+		Validate: func(member gossip.Member) error {
+			return validateServer(&models.CreateServer{Name: member.Name, VirtualNodes: member.VirtualNodes, Weight: member.Weight})
+		},
+		Joined: func(member gossip.Member) {
+			log.Printf("moving keys onto %s", member.Name)
+			reconcileBeforeMoving(member.Name)
+			server := &models.CreateServer{Name: member.Name, VirtualNodes: member.VirtualNodes, Weight: member.Weight}
+			if _, err := AddServerService(server); err != nil {
+				log.Printf("adding %s: %v", member.Name, err)
+			}
+		},
+		Left: func(member gossip.Member) {
+			log.Printf("handing off the keys of %s", member.Name)
+			reconcileBeforeMoving(member.Name)
+			DeleteServerService(member.Name)
+		},
+		Changed: saveRingState,
+	}
+
+	list, err := handlers.NewMemberlist(config)
+	if err != nil {
+		return err
+	}
+	memberlist = list
+	return list.Join(seeds)
+}
+
+// reconcileBeforeMoving rebuilds the key bookkeeping from Redis. Each
+// instance only tracks the keys written through it, so without this a
+// migration misses keys.
+func reconcileBeforeMoving(member string) {
+	if _, err := ReconcileService(); err != nil {
+		log.Printf("reconcile before moving the keys of %s: %v", member, err)
+	}
+}
+
+// LeaveMembershipService tells the cluster this instance is going away
+func LeaveMembershipService() {
+	if memberlist == nil {
+		return
+	}
+	memberlist.Leave()
+	memberlist.Shutdown()
+}
+
+func GetMembersService() ([]models.Member, error) {
+	if memberlist == nil {
+		return nil, ErrMembershipDisabled
+	}
+	members := memberlist.Members()
+	result := make([]models.Member, len(members))
+	for i, member := range members {
+		result[i] = models.Member{
+			Name:         member.Name,
+			Addr:         member.Addr,
+			Weight:       member.Weight,
+			VirtualNodes: member.VirtualNodes,
+			Incarnation:  member.Incarnation,
+			State:        member.State.String(),
+		}
+	}
+	return result, nil
+}
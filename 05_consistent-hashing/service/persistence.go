@@ -0,0 +1,116 @@
+package service
+
+import (
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/AVVKavvk/consistent-hashing/algo"
+	"github.com/AVVKavvk/consistent-hashing/models"
+	"github.com/AVVKavvk/consistent-hashing/redisClient"
+	"github.com/AVVKavvk/consistent-hashing/ringstore"
+)
+
+var (
+	ringStore ringstore.Store
+	// dirty is set by writes; the save loop flushes it every interval
+	dirty atomic.Bool
+)
+
+// StartPersistenceService restores the ring from store, reconciles it with
+// the keys actually in Redis and then keeps store up to date: membership
+// changes are saved right away, key bookkeeping at most every interval.
+func StartPersistenceService(store ringstore.Store, interval time.Duration) (models.ReconcileReport, error) {
+	hr := algo.GetHashRing()
+
+	state, err := store.Load()
+	switch {
+	case err == ringstore.ErrNoState:
+	case err != nil:
+		return models.ReconcileReport{}, err
+	default:
+		version := hr.Restore(*state)
+		log.Printf("restored %d servers from the saved ring state, ring version %d", len(state.Nodes), version)
+	}
+
+	report, err := ReconcileService()
+	if err != nil {
+		return report, err
+	}
+
+	ringStore = store
+	saveRingState()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if dirty.Swap(false) {
+				saveRingState()
+			}
+		}
+	}()
+	return report, nil
+}
+
+// ReconcileService rebuilds every server's hashes and userIds from the keys
+// in Redis, which are the source of truth, and reports what differed
+func ReconcileService() (models.ReconcileReport, error) {
+	hr := algo.GetHashRing()
+	r := hr.Snapshot()
+
+	report := models.ReconcileReport{Added: make(map[string]int), Removed: make(map[string]int)}
+	hashes := make(map[string][]int)
+	userIds := make(map[string][]string)
+
+	scanned, err := redisClient.ScanUserData(func(nodeName string, hash int, user *models.User) {
+		if !r.Has(nodeName) {
+			report.Orphaned++
+			return
+		}
+		hashes[nodeName] = append(hashes[nodeName], hash)
+		userIds[nodeName] = append(userIds[nodeName], user.ID)
+	})
+	report.Scanned = scanned
+	if err != nil {
+		return report, err
+	}
+
+	for _, nodeName := range r.Nodes() {
+		before := make(map[int]bool)
+		for _, hash := range hr.GetHashesForNode(nodeName) {
+			before[hash] = true
+		}
+		for _, hash := range hashes[nodeName] {
+			if before[hash] {
+				delete(before, hash)
+			} else {
+				report.Added[nodeName]++
+			}
+		}
+		if len(before) > 0 {
+			report.Removed[nodeName] = len(before)
+		}
+		hr.SetNodeKeys(nodeName, hashes[nodeName], userIds[nodeName])
+	}
+	hr.RecountOwners()
+
+	markDirty()
+	return report, nil
+}
+
+func markDirty() {
+	dirty.Store(true)
+}
+
+// saveRingState writes the ring to the store now. A failed save is only
+// logged: the next one, or the reconciliation at startup, catches up.
+func saveRingState() {
+	if ringStore == nil {
+		return
+	}
+	dirty.Store(false)
+	if err := ringStore.Save(algo.GetHashRing().Export()); err != nil {
+		log.Printf("saving ring state: %v", err)
+	}
+}
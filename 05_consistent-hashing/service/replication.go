@@ -0,0 +1,161 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/AVVKavvk/consistent-hashing/algo"
+	"github.com/AVVKavvk/consistent-hashing/models"
+	"github.com/AVVKavvk/consistent-hashing/redisClient"
+)
+
+var (
+	replicationMu sync.RWMutex
+	replication   = models.ReplicationConfig{
+		N: envInt("REPLICATION_N", 3),
+		R: envInt("REPLICATION_R", 2),
+		W: envInt("REPLICATION_W", 2),
+	}
+
+	ErrInvalidReplication = errors.New("replication needs 1 <= r <= n and 1 <= w <= n")
+	ErrQuorum             = errors.New("not enough replicas answered")
+	ErrNoServers          = errors.New("no servers on the ring")
+)
+
+func GetReplicationService() models.ReplicationConfig {
+	replicationMu.RLock()
+	defer replicationMu.RUnlock()
+	return replication
+}
+
+// SetReplicationService changes N/R/W. Raising N does not copy existing keys
+// until they are rewritten, read-repaired or moved by a join/leave.
+func SetReplicationService(config *models.ReplicationConfig) (models.ReplicationConfig, error) {
+	if config.N < 1 || config.R < 1 || config.W < 1 || config.R > config.N || config.W > config.N {
+		return GetReplicationService(), ErrInvalidReplication
+	}
+	replicationMu.Lock()
+	defer replicationMu.Unlock()
+	replication = *config
+	return replication, nil
+}
+
+// quorum caps R or W at the number of replicas that exist, so a ring with
+// fewer servers than N still accepts reads and writes
+func quorum(want int, replicas int) int {
+	if want > replicas {
+		return replicas
+	}
+	return want
+}
+
+// rebalance makes the servers holding each key match its preference list
+// after a join or leave: missing replicas get a copy, servers that dropped
+// out of the list lose theirs. holders is the GetKeyHolders snapshot taken
+// before the ring changed.
+func rebalance(holders map[int][]string) (copied map[string]int, dropped map[string]int) {
+	hr := algo.GetHashRing()
+	n := GetReplicationService().N
+
+	copied = make(map[string]int)
+	dropped = make(map[string]int)
+	var countMu sync.Mutex
+	var wg sync.WaitGroup
+
+	// copies waiting for an unreachable owner are kept until replayed
+	hinted := hintedCopies()
+
+	for hash, nodes := range holders {
+		want, _ := hr.GetPreferenceList(hash, n)
+		if len(want) == 0 {
+			continue
+		}
+		// the first server in the list owns the key from now on
+		hr.AssignOwner(hash, want[0])
+
+		var toCopy, toDrop []string
+		for _, nodeName := range want {
+			if !containsString(nodes, nodeName) {
+				toCopy = append(toCopy, nodeName)
+			}
+		}
+		for _, nodeName := range nodes {
+			if !containsString(want, nodeName) && !hinted[nodeName+":"+strconv.Itoa(hash)] {
+				toDrop = append(toDrop, nodeName)
+			}
+		}
+		if len(toCopy) == 0 && len(toDrop) == 0 {
+			continue
+		}
+
+		wg.Add(1)
+		go func(hash int, sources []string, toCopy []string, toDrop []string) {
+			defer wg.Done()
+			if err := rebalanceHelper(hash, sources, toCopy, toDrop); err != nil {
+				return
+			}
+			countMu.Lock()
+			for _, nodeName := range toCopy {
+				copied[nodeName]++
+			}
+			for _, nodeName := range toDrop {
+				dropped[nodeName]++
+			}
+			countMu.Unlock()
+		}(hash, nodes, toCopy, toDrop)
+	}
+	wg.Wait()
+	return copied, dropped
+}
+
+func rebalanceHelper(hash int, sources []string, toCopy []string, toDrop []string) error {
+	hr := algo.GetHashRing()
+	hashStr := strconv.Itoa(hash)
+
+	// take the newest copy any current holder has
+	var userData *models.User
+	for _, nodeName := range sources {
+		user, err := redisClient.GetUserDataWithHashFromRedisWithNode(nodeName, hashStr)
+		if err == nil && (userData == nil || user.Version > userData.Version) {
+			userData = user
+		}
+	}
+	if userData == nil {
+		return fmt.Errorf("no copy of %s found on %v", hashStr, sources)
+	}
+
+	for _, nodeName := range toCopy {
+		// never overwrite a write that already reached the new replica
+		if err := redisClient.StoreUserDataWithHashToRedisWithNodeIfNewer(nodeName, hashStr, userData); err != nil {
+			return err
+		}
+		hr.AddHashToNode(nodeName, hash)
+		hr.AddUserIdToNode(nodeName, userData.ID)
+	}
+	for _, nodeName := range toDrop {
+		if err := redisClient.DeleteUserDataWithHashFromRedisWithNode(nodeName, hashStr); err != nil {
+			return err
+		}
+		hr.RemoveHashFromNode(nodeName, hash, userData.ID)
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func envInt(key string, fallback int) int {
+	if val, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return val
+	}
+	return fallback
+}
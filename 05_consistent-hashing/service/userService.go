@@ -1,38 +1,196 @@
 package service
 
 import (
+	"errors"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/AVVKavvk/consistent-hashing/algo"
 	"github.com/AVVKavvk/consistent-hashing/models"
 	"github.com/AVVKavvk/consistent-hashing/redisClient"
+	"github.com/go-redis/redis"
 )
 
+// writeAck is one replica's answer to a write. holder is set when the
+// replica was unreachable and the write was handed off.
+type writeAck struct {
+	replica string
+	holder  string
+	ok      bool
+}
+
+// replicaResult is one replica's answer to a read
+type replicaResult struct {
+	serverName string
+	user       *models.User // nil when the replica does not have the key
+	err        error
+}
+
 func AddUserDataService(user *models.User) (*models.ResponseModel, error) {
 	id := user.ID
 	hr := algo.GetHashRing()
+	config := GetReplicationService()
 
-	serverName, hash := hr.GetOwner(id)
+	// ClaimOwner applies bounded loads to new keys and counts them towards
+	// the owner's load
+	serverName, hash, isNew := hr.ClaimOwner(id)
 	hashStr := strconv.Itoa(hash)
+	replicas, ringVersion := hr.GetPreferenceList(hash, config.N)
+	if len(replicas) == 0 {
+		return nil, ErrNoServers
+	}
+	w := quorum(config.W, len(replicas))
+
+	// microseconds fit in the float64 numbers the read-repair script compares
+	user.Version = time.Now().UnixMicro()
 
-	hr.AddHashToNode(serverName, hash)
-	hr.AddUserIdToNode(serverName, id)
+	fallbacks := fallbackNodes(hash, replicas)
+	acks := make(chan writeAck, len(replicas))
+	for _, replica := range replicas {
+		go func(replica string) {
+			if err := redisClient.StoreUserDataWithHashToRedisWithNode(replica, hashStr, user); err != nil {
+				// hinted handoff: a server past the preference list keeps
+				// the copy until the replica is back
+				holder, err := handOff(replica, hash, user, fallbacks)
+				acks <- writeAck{replica: replica, holder: holder, ok: err == nil}
+				return
+			}
+			hr.AddHashToNode(replica, hash)
+			hr.AddUserIdToNode(replica, id)
+			acks <- writeAck{replica: replica, ok: true}
+		}(replica)
+	}
 
-	err := redisClient.StoreUserDataWithHashToRedisWithNode(serverName, hashStr, user)
-	if err != nil {
-		return nil, err
+	// a handed off copy counts towards W, like Dynamo's sloppy quorum
+	acked := make([]string, 0, len(replicas))
+	hinted := make(map[string]string)
+	for range replicas {
+		if ack := <-acks; ack.ok {
+			acked = append(acked, ack.replica)
+			if ack.holder != "" {
+				hinted[ack.replica] = ack.holder
+			}
+		}
+		if len(acked) >= w {
+			break
+		}
+	}
+	if len(acked) < w {
+		if isNew {
+			hr.ReleaseOwner(hash)
+		}
+		return nil, fmt.Errorf("%w: write acknowledged by %d of %d replicas, need %d", ErrQuorum, len(acked), len(replicas), w)
 	}
+	markDirty()
 
-	return &models.ResponseModel{ServerName: serverName, Hash: hashStr, Users: []models.User{*user}}, nil
+	return &models.ResponseModel{ServerName: serverName, Hash: hashStr, Users: []models.User{*user}, Replicas: acked, HintedTo: hinted, RingVersion: ringVersion}, nil
 }
 
 func GetUserByIdService(userId string) (*models.ResponseModel, error) {
 	hr := algo.GetHashRing()
+	config := GetReplicationService()
+
 	serverName, hash := hr.GetOwner(userId)
 	hashStr := strconv.Itoa(hash)
-	user, err := redisClient.GetUserDataWithHashFromRedisWithNode(serverName, hashStr)
-	if err != nil {
-		return nil, err
+	replicas, ringVersion := hr.GetPreferenceList(hash, config.N)
+	if len(replicas) == 0 {
+		return nil, ErrNoServers
+	}
+	r := quorum(config.R, len(replicas))
+
+	results := make(chan replicaResult, len(replicas))
+	for _, replica := range replicas {
+		go func(replica string) {
+			user, err := redisClient.GetUserDataWithHashFromRedisWithNode(replica, hashStr)
+			if errors.Is(err, redisClient.ErrNodeUnreachable) {
+				// the replica's latest write may be waiting on a hint
+				if hinted, hintErr := readHinted(replica, hashStr); hintErr == nil {
+					user, err = hinted, nil
+				}
+			}
+			if err == redis.Nil {
+				err = nil
+			}
+			results <- replicaResult{serverName: replica, user: user, err: err}
+		}(replica)
+	}
+
+	// answer as soon as R replicas replied, the rest are only used for repair
+	answered := make([]replicaResult, 0, len(replicas))
+	failed := 0
+	for len(answered) < r && len(answered)+failed < len(replicas) {
+		res := <-results
+		if res.err != nil {
+			failed++
+			continue
+		}
+		answered = append(answered, res)
+	}
+	if len(answered) < r {
+		return nil, fmt.Errorf("%w: read answered by %d of %d replicas, need %d", ErrQuorum, len(answered), len(replicas), r)
+	}
+
+	latest := latestVersion(answered)
+	go readRepair(hash, latest, answered, results, len(replicas)-len(answered)-failed)
+
+	if latest == nil {
+		// The owner may still be receiving this key after joining the ring
+		if oldServerName := hr.GetFallbackOwner(serverName, hash); oldServerName != "" {
+			user, err := redisClient.GetUserDataWithHashFromRedisWithNode(oldServerName, hashStr)
+			if err != nil {
+				return nil, err
+			}
+			return &models.ResponseModel{ServerName: oldServerName, Hash: hashStr, Users: []models.User{*user}, RingVersion: ringVersion}, nil
+		}
+		return nil, redis.Nil
+	}
+
+	names := make([]string, len(answered))
+	for i, res := range answered {
+		names[i] = res.serverName
+	}
+	return &models.ResponseModel{ServerName: serverName, Hash: hashStr, Users: []models.User{*latest}, Replicas: names, RingVersion: ringVersion}, nil
+}
+
+// readRepair writes the newest copy to every replica that answered with an
+// older one or none, including replicas that answered after the quorum
+func readRepair(hash int, latest *models.User, answered []replicaResult, late chan replicaResult, pending int) {
+	all := append([]replicaResult{}, answered...)
+	for i := 0; i < pending; i++ {
+		if res := <-late; res.err == nil {
+			all = append(all, res)
+		}
+	}
+
+	if newest := latestVersion(all); newest != nil && (latest == nil || newest.Version > latest.Version) {
+		latest = newest
+	}
+	if latest == nil {
+		return
+	}
+
+	hr := algo.GetHashRing()
+	hashStr := strconv.Itoa(hash)
+	for _, res := range all {
+		if res.user != nil && res.user.Version >= latest.Version {
+			continue
+		}
+		if err := redisClient.StoreUserDataWithHashToRedisWithNodeIfNewer(res.serverName, hashStr, latest); err != nil {
+			continue
+		}
+		hr.AddHashToNode(res.serverName, hash)
+		hr.AddUserIdToNode(res.serverName, latest.ID)
+		markDirty()
+	}
+}
+
+func latestVersion(results []replicaResult) *models.User {
+	var latest *models.User
+	for _, res := range results {
+		if res.user != nil && (latest == nil || res.user.Version > latest.Version) {
+			latest = res.user
+		}
 	}
-	return &models.ResponseModel{ServerName: serverName, Hash: hashStr, Users: []models.User{*user}}, nil
+	return latest
 }
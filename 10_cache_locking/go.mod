@@ -7,6 +7,7 @@ toolchain go1.24.12
 require (
 	github.com/go-redis/redis v6.15.9+incompatible
 	github.com/labstack/echo/v4 v4.15.0
+	golang.org/x/sync v0.19.0
 )
 
 require (
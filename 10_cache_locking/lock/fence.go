@@ -0,0 +1,35 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// setFencedScript writes a value only if the writer's fencing token is at
+// least the last one that wrote the key.
+//
+// KEYS[1] value, KEYS[2] last fencing token that wrote it
+// ARGV[1] value, ARGV[2] ttl in milliseconds, ARGV[3] fencing token
+// returns 1 if written, 0 if a newer holder already wrote
+var setFencedScript = redis.NewScript(`
+local last = tonumber(redis.call('GET', KEYS[2]) or '0')
+if tonumber(ARGV[3]) < last then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+redis.call('SET', KEYS[2], ARGV[3])
+return 1
+`)
+
+// SetFenced stores value under key unless a holder with a larger fencing
+// token already did. A holder that stalled past its lease and lost the lock
+// then cannot overwrite what the next holder wrote. The last token is kept
+// under key + ":fence".
+func SetFenced(rdb redis.Cmdable, key string, value interface{}, ttl time.Duration, fence int64) (bool, error) {
+	written, err := setFencedScript.Run(rdb, []string{key, key + ":fence"}, value, ttl.Milliseconds(), fence).Int64()
+	if err != nil {
+		return false, err
+	}
+	return written == 1, nil
+}
@@ -0,0 +1,186 @@
+// Package lock is a Redis lock for one key at a time. Each holder has its
+// own owner token, so only the holder can extend or release the lock, and a
+// fencing token that grows with every acquisition, so writes made by a
+// holder that lost the lock can be told apart from newer ones.
+package lock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+var (
+	ErrNotAcquired = errors.New("lock is held by another owner")
+	ErrLockLost    = errors.New("lock expired or was taken by another owner")
+)
+
+// acquireScript takes the lock and draws the next fencing token in one
+// step, so two holders can never get the same token.
+//
+// KEYS[1] lock, KEYS[2] fencing counter
+// ARGV[1] owner token, ARGV[2] ttl in milliseconds
+// returns the fencing token, or 0 if the lock is held
+var acquireScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lock only if it still belongs to the owner, an
+// unconditional DEL would free a lock someone else took after ours expired
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// extendScript resets the lease, again only for the owner
+var extendScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+type Locker struct {
+	rdb redis.Cmdable
+}
+
+func New(rdb redis.Cmdable) *Locker {
+	return &Locker{rdb: rdb}
+}
+
+// Lock is one acquisition of a key
+type Lock struct {
+	rdb   redis.Cmdable
+	key   string
+	token string
+	fence int64
+	ttl   time.Duration
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	lostOnce sync.Once
+	lost     chan struct{}
+}
+
+// TryAcquire takes the lock on key for ttl, or returns ErrNotAcquired
+// without waiting if another owner holds it
+func (l *Locker) TryAcquire(key string, ttl time.Duration) (*Lock, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	fence, err := acquireScript.Run(l.rdb, []string{key, key + ":fence"}, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrNotAcquired
+	}
+
+	return &Lock{
+		rdb:   l.rdb,
+		key:   key,
+		token: token,
+		fence: fence,
+		ttl:   ttl,
+		stop:  make(chan struct{}),
+		lost:  make(chan struct{}),
+	}, nil
+}
+
+// Token is the owner token stored in the lock key
+func (lk *Lock) Token() string {
+	return lk.token
+}
+
+// Fence is the fencing token of this acquisition, larger than that of any
+// earlier acquisition of the same key
+func (lk *Lock) Fence() int64 {
+	return lk.fence
+}
+
+// Lost is closed once the watchdog finds the lock gone
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Extend resets the lease to ttl from now
+func (lk *Lock) Extend(ttl time.Duration) error {
+	extended, err := extendScript.Run(lk.rdb, []string{lk.key}, lk.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if extended == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// StartWatchdog extends the lease every third of the ttl until Release, so
+// work that takes longer than the ttl keeps the lock. If the lock is gone,
+// or Redis cannot be reached for a whole ttl, Lost is closed and the
+// watchdog stops.
+func (lk *Lock) StartWatchdog() {
+	go func() {
+		ticker := time.NewTicker(lk.ttl / 3)
+		defer ticker.Stop()
+
+		lastExtended := time.Now()
+		for {
+			select {
+			case <-lk.stop:
+				return
+			case <-ticker.C:
+			}
+
+			err := lk.Extend(lk.ttl)
+			switch {
+			case err == nil:
+				lastExtended = time.Now()
+				continue
+			case errors.Is(err, ErrLockLost):
+			case time.Since(lastExtended) < lk.ttl:
+				// the lease has not run out yet, try again next tick
+				log.Printf("lock %s: extending lease failed: %v", lk.key, err)
+				continue
+			}
+			lk.lostOnce.Do(func() { close(lk.lost) })
+			return
+		}
+	}()
+}
+
+// Release stops the watchdog and deletes the lock if it is still ours.
+// ErrLockLost means it expired earlier; whatever it protected may have run
+// concurrently with another owner.
+func (lk *Lock) Release() error {
+	lk.stopOnce.Do(func() { close(lk.stop) })
+
+	released, err := releaseScript.Run(lk.rdb, []string{lk.key}, lk.token).Int64()
+	if err != nil {
+		return err
+	}
+	if released == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -8,10 +8,39 @@ import (
 	"math"
 	"time"
 
+	"github.com/AVVKavvk/cache_locking/lock"
 	"github.com/AVVKavvk/cache_locking/utils"
+	"golang.org/x/sync/singleflight"
 )
 
+// dashboardFlights lets concurrent lookups of the same key in this process
+// share one call, so only one goroutine per instance polls Redis and
+// contends for the distributed lock
+var dashboardFlights singleflight.Group
+
 func GetOrUpdateProductsForDashboardWithBackoff(c context.Context, key string) (map[string]interface{}, error) {
+	// The shared call must not fail for everyone when the caller that started
+	// it goes away, it is still bounded by maxRetries
+	ch := dashboardFlights.DoChan(key, func() (interface{}, error) {
+		return getOrUpdateProductsForDashboardWithBackoff(context.WithoutCancel(c), key)
+	})
+
+	select {
+	case <-c.Done():
+		log.Printf("[%s] Request CANCELLED - Context done: %v", getRequestID(c), c.Err())
+		return nil, c.Err()
+	case result := <-ch:
+		if result.Shared {
+			log.Printf("[%s] SINGLEFLIGHT - Result shared with other requests for key: %s", getRequestID(c), key)
+		}
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(map[string]interface{}), nil
+	}
+}
+
+func getOrUpdateProductsForDashboardWithBackoff(c context.Context, key string) (map[string]interface{}, error) {
 	rdb := GetRedisClient()
 
 	lockKey := key + ":lock"
@@ -27,52 +56,51 @@ func GetOrUpdateProductsForDashboardWithBackoff(c context.Context, key string) (
 		// 1. Try to get the data with key
 		dataStr := rdb.Get(key)
 		if dataStr.Err() == nil {
-			log.Printf("[%s] Cache HIT - Data found in cache", getRequestID(c))
 			bytesData, err := dataStr.Bytes()
 			if err != nil {
 				log.Printf("[%s] ERROR - Failed to convert cache data to bytes: %v", getRequestID(c), err)
 				return nil, err
 			}
-			return FromJSON[map[string]interface{}](bytesData)
+			entry, err := FromJSON[cacheEntry](bytesData)
+			if err != nil {
+				log.Printf("[%s] ERROR - Failed to decode cache entry: %v", getRequestID(c), err)
+				return nil, err
+			}
+
+			// Entries without data were written before soft TTLs, refresh them like a miss
+			if entry.Data != nil {
+				now := time.Now()
+				switch {
+				case now.UnixMilli() >= entry.SoftExpiry:
+					log.Printf("[%s] Cache STALE - Serving stale data, refreshing in background", getRequestID(c))
+					refreshInBackground(c, key, entry)
+				case xfetch(entry, now):
+					log.Printf("[%s] Cache EARLY REFRESH - Serving cached data, refreshing before soft expiry", getRequestID(c))
+					refreshInBackground(c, key, entry)
+				default:
+					log.Printf("[%s] Cache HIT - Data found in cache", getRequestID(c))
+				}
+				return entry.Data, nil
+			}
 		}
 
 		log.Printf("[%s] Cache MISS - Data not found, attempting to acquire lock", getRequestID(c))
 
 		// 2. Attempt to become the leader and set data to redis
-		locked, err := rdb.SetNX(lockKey, "locked", 10*time.Second).Result()
+		lk, err := lock.New(rdb).TryAcquire(lockKey, 10*time.Second)
 
-		if err != nil {
+		if err != nil && !errors.Is(err, lock.ErrNotAcquired) {
 			log.Printf("[%s] ERROR - Failed to acquire lock: %v", getRequestID(c), err)
 			return nil, err
 		}
 
-		if locked {
-			log.Printf("[%s] LOCK ACQUIRED - This request will update the cache", getRequestID(c))
-			defer func() {
-				rdb.Del(lockKey)
-				log.Printf("[%s] LOCK RELEASED", getRequestID(c))
-			}()
-
-			log.Printf("[%s] Fetching data from external service...", getRequestID(c))
-			time.Sleep(5 * time.Second) // Simulate backoff
-			products, err := externalServiceForDashboardData()
-
-			if err != nil {
-				log.Printf("[%s] ERROR - External service failed: %v", getRequestID(c), err)
-				return nil, err
-			}
-
-			log.Printf("[%s] External service returned %d products", getRequestID(c), len(products))
-
-			bytesData, err := ToJSON(products)
-			if err != nil {
-				log.Printf("[%s] ERROR - Failed to marshal products to JSON: %v", getRequestID(c), err)
-				return nil, err
-			}
+		if err == nil {
+			log.Printf("[%s] LOCK ACQUIRED - This request will update the cache (fence: %d)", getRequestID(c), lk.Fence())
+			// Keep the lease alive while the external service is slow
+			lk.StartWatchdog()
+			defer releaseLock(c, lk)
 
-			rdb.Set(key, bytesData, 10*time.Minute)
-			log.Printf("[%s] Cache UPDATED successfully (TTL: 10 minutes)", getRequestID(c))
-			return products, nil
+			return refreshCache(c, lk, key)
 		} else {
 			log.Printf("[%s] LOCK BUSY - Another request is updating the cache, waiting...", getRequestID(c))
 		}
@@ -99,6 +127,55 @@ func GetOrUpdateProductsForDashboardWithBackoff(c context.Context, key string) (
 	return nil, errors.New("request timed out waiting for cache update")
 }
 
+// refreshCache fetches fresh data and writes it while holding lk
+func refreshCache(c context.Context, lk *lock.Lock, key string) (map[string]interface{}, error) {
+	rdb := GetRedisClient()
+
+	log.Printf("[%s] Fetching data from external service...", getRequestID(c))
+	start := time.Now()
+	time.Sleep(5 * time.Second) // Simulate backoff
+	products, err := externalServiceForDashboardData()
+
+	if err != nil {
+		log.Printf("[%s] ERROR - External service failed: %v", getRequestID(c), err)
+		return nil, err
+	}
+
+	log.Printf("[%s] External service returned %d products", getRequestID(c), len(products))
+
+	delta := time.Since(start)
+	bytesData, err := ToJSON(cacheEntry{
+		Data:       products,
+		SoftExpiry: time.Now().Add(softTTL).UnixMilli(),
+		Delta:      delta.Milliseconds(),
+	})
+	if err != nil {
+		log.Printf("[%s] ERROR - Failed to marshal products to JSON: %v", getRequestID(c), err)
+		return nil, err
+	}
+
+	// A holder that lost the lock must not overwrite data written by a newer one
+	written, err := lock.SetFenced(rdb, key, bytesData, hardTTL, lk.Fence())
+	if err != nil {
+		log.Printf("[%s] ERROR - Failed to update cache: %v", getRequestID(c), err)
+		return nil, err
+	}
+	if !written {
+		log.Printf("[%s] Cache NOT UPDATED - A newer lock holder already wrote it (fence: %d)", getRequestID(c), lk.Fence())
+		return products, nil
+	}
+	log.Printf("[%s] Cache UPDATED successfully (soft TTL: %v, hard TTL: %v, recompute: %v)", getRequestID(c), softTTL, hardTTL, delta)
+	return products, nil
+}
+
+func releaseLock(c context.Context, lk *lock.Lock) {
+	if err := lk.Release(); err != nil {
+		log.Printf("[%s] LOCK LOST - Lease expired before release: %v", getRequestID(c), err)
+		return
+	}
+	log.Printf("[%s] LOCK RELEASED", getRequestID(c))
+}
+
 // Helper function to get or generate a request ID from context
 func getRequestID(c context.Context) string {
 	return utils.GetRequestIDFromContext(c)
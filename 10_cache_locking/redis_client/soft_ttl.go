@@ -0,0 +1,78 @@
+package redis_client
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/AVVKavvk/cache_locking/lock"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// softTTL is how long data counts as fresh. After it, readers get the
+	// stale data and one request refreshes it in the background.
+	softTTL = 10 * time.Minute
+	// hardTTL is how long Redis keeps the key. Only after it do readers
+	// wait on the lock path.
+	hardTTL = time.Hour
+	// xfetchBeta scales early recomputation, above 1 refreshes earlier
+	xfetchBeta = 1.0
+)
+
+// cacheEntry is the value stored in Redis
+type cacheEntry struct {
+	Data       map[string]interface{} `json:"data"`
+	SoftExpiry int64                  `json:"soft_expiry"` // unix milliseconds
+	Delta      int64                  `json:"delta"`       // milliseconds the last recompute took
+}
+
+// refreshFlights keeps this process to one background refresh per key
+var refreshFlights singleflight.Group
+
+// xfetch decides whether to refresh before the soft expiry (XFetch, from
+// "Optimal Probabilistic Cache Stampede Prevention"). The chance grows as the
+// expiry nears, and keys that are slow to recompute start earlier, so hot
+// keys are refreshed before they go stale.
+func xfetch(entry cacheEntry, now time.Time) bool {
+	gap := -float64(entry.Delta) * xfetchBeta * math.Log(1-rand.Float64())
+	return float64(now.UnixMilli())+gap >= float64(entry.SoftExpiry)
+}
+
+// refreshInBackground refreshes key without making the caller wait. seen is
+// the entry the caller read. Other instances are kept out by the distributed
+// lock; if it is busy someone is already refreshing.
+func refreshInBackground(c context.Context, key string, seen cacheEntry) {
+	c = context.WithoutCancel(c)
+
+	refreshFlights.DoChan(key, func() (interface{}, error) {
+		rdb := GetRedisClient()
+
+		lk, err := lock.New(rdb).TryAcquire(key+":lock", 10*time.Second)
+		if errors.Is(err, lock.ErrNotAcquired) {
+			log.Printf("[%s] REFRESH SKIPPED - Another request is already refreshing", getRequestID(c))
+			return nil, nil
+		}
+		if err != nil {
+			log.Printf("[%s] ERROR - Failed to acquire lock for background refresh: %v", getRequestID(c), err)
+			return nil, err
+		}
+		lk.StartWatchdog()
+		defer releaseLock(c, lk)
+
+		// Another instance may have refreshed between our read and the lock
+		if bytesData, err := rdb.Get(key).Bytes(); err == nil {
+			entry, err := FromJSON[cacheEntry](bytesData)
+			if err == nil && entry.SoftExpiry != seen.SoftExpiry {
+				log.Printf("[%s] REFRESH SKIPPED - Cache was refreshed meanwhile", getRequestID(c))
+				return nil, nil
+			}
+		}
+
+		log.Printf("[%s] BACKGROUND REFRESH - Updating cache for key: %s", getRequestID(c), key)
+		return refreshCache(c, lk, key)
+	})
+}
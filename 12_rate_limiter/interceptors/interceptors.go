@@ -0,0 +1,175 @@
+// Package interceptors rate limits gRPC servers with the same algorithms
+// the HTTP middlewares use. Rejected calls fail with ResourceExhausted and
+// carry a RetryInfo detail saying when to try again.
+package interceptors
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+
+	"github.com/AVVKavvk/rate_limiter/keystore"
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// KeyFunc picks the client a call is counted for
+type KeyFunc func(ctx context.Context) string
+
+// PeerKey counts calls by the host of the peer address
+func PeerKey(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "unknown"
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// MetadataKey counts calls by the first value of a metadata key, such as
+// an API key, if valid accepts it. The client picks its metadata, so a
+// value that is missing or not accepted is counted by peer: sending a new
+// random key on every call does not get a fresh budget.
+func MetadataKey(name string, valid func(value string) bool) KeyFunc {
+	return func(ctx context.Context) string {
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get(name); len(values) > 0 && valid(values[0]) {
+				return name + "=" + values[0]
+			}
+		}
+		return PeerKey(ctx)
+	}
+}
+
+// KnownValues accepts only the given values, e.g. the API keys that were
+// issued. Keys that are checked elsewhere, such as by an auth interceptor
+// that runs first, can pass their own func to MetadataKey instead.
+func KnownValues(values ...string) func(string) bool {
+	known := make(map[string]bool, len(values))
+	for _, v := range values {
+		if v != "" {
+			known[v] = true
+		}
+	}
+	return func(value string) bool {
+		return known[value]
+	}
+}
+
+// Limiter keeps one rate_limiter.Limiter per client
+type Limiter struct {
+	key     KeyFunc
+	create  func(key string) rate_limiter.Limiter
+	clients *keystore.Store[rate_limiter.Limiter]
+
+	// LimitMessages counts every message a client sends on a stream as
+	// well, not only the call that opens it
+	LimitMessages bool
+}
+
+// New creates a limiter. create builds the limiter of a new client, e.g.
+//
+//	func(string) rate_limiter.Limiter { return rate_limiter.GetNewTokenBucket(10, 60) }
+func New(key KeyFunc, create func(key string) rate_limiter.Limiter, store keystore.Config) *Limiter {
+	return &Limiter{
+		key:    key,
+		create: create,
+		clients: keystore.New(store, func(_ string, limiter rate_limiter.Limiter) {
+			if s, ok := limiter.(interface{ Stop() }); ok {
+				s.Stop()
+			}
+		}),
+	}
+}
+
+// check takes one request from the caller's limiter, returning the
+// ResourceExhausted error to send when it is over the limit
+func (l *Limiter) check(ctx context.Context) (rate_limiter.Quota, error) {
+	key := l.key(ctx)
+	limiter, _ := l.clients.GetOrCreate(key, func() rate_limiter.Limiter {
+		return l.create(key)
+	})
+
+	allowed := limiter.Allow()
+	quota := limiter.Quota()
+	if allowed {
+		return quota, nil
+	}
+
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded, retry after "+quota.RetryAfter.String())
+	detailed, err := st.WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(quota.RetryAfter)},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     key,
+			Description: strconv.Itoa(quota.Limit) + " requests, " + strconv.Itoa(quota.Remaining) + " remaining",
+		}}},
+	)
+	if err != nil {
+		return quota, st.Err()
+	}
+	return quota, detailed.Err()
+}
+
+// quotaHeader is the quota as response metadata, like the RateLimit
+// headers of the HTTP middlewares
+func quotaHeader(quota rate_limiter.Quota) metadata.MD {
+	return metadata.Pairs(
+		"ratelimit-limit", strconv.Itoa(quota.Limit),
+		"ratelimit-remaining", strconv.Itoa(quota.Remaining),
+		"ratelimit-reset", strconv.FormatInt(int64(math.Ceil(quota.Reset.Seconds())), 10),
+	)
+}
+
+// UnaryServerInterceptor limits every unary call
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		quota, err := l.check(ctx)
+		// headers are best effort, a failure must not fail the call
+		_ = grpc.SetHeader(ctx, quotaHeader(quota))
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor limits opening streams and, with LimitMessages,
+// every message received on them
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		quota, err := l.check(ss.Context())
+		_ = ss.SetHeader(quotaHeader(quota))
+		if err != nil {
+			return err
+		}
+		if l.LimitMessages {
+			ss = &limitedStream{ServerStream: ss, limiter: l}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// limitedStream fails RecvMsg once the client sends faster than its limit,
+// which ends the call with ResourceExhausted
+type limitedStream struct {
+	grpc.ServerStream
+	limiter *Limiter
+}
+
+func (s *limitedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	_, err := s.limiter.check(s.Context())
+	return err
+}
@@ -0,0 +1,208 @@
+// Package keystore holds per-client limiter state in a bounded map. Keys are
+// spread over shards with their own locks, so clients do not wait on each
+// other, and each shard drops its least recently used key when full.
+package keystore
+
+import (
+	"container/list"
+	"hash/fnv"
+	"log"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	// Shards is the number of independently locked parts, 32 by default
+	Shards int
+	// MaxEntries bounds the keys across all shards, 0 means unbounded
+	MaxEntries int
+	// IdleTTL drops keys not used for this long, 0 keeps them until evicted
+	IdleTTL time.Duration
+}
+
+// Store maps client keys to values of type V
+type Store[V any] struct {
+	shards  []*shard[V]
+	onEvict func(key string, value V)
+}
+
+type shard[V any] struct {
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List // of *entry[V], most recently used first
+	max   int
+}
+
+type entry[V any] struct {
+	key      string
+	value    V
+	lastUsed time.Time
+}
+
+// New creates a store. onEvict, if not nil, is called for every value that
+// leaves the store: evicted, expired, replaced or deleted. It runs without
+// the shard lock held.
+func New[V any](config Config, onEvict func(key string, value V)) *Store[V] {
+	if config.Shards <= 0 {
+		config.Shards = 32
+	}
+	perShard := 0
+	if config.MaxEntries > 0 {
+		// round up so the store holds at least MaxEntries
+		perShard = (config.MaxEntries + config.Shards - 1) / config.Shards
+	}
+
+	s := &Store[V]{shards: make([]*shard[V], config.Shards), onEvict: onEvict}
+	for i := range s.shards {
+		s.shards[i] = &shard[V]{items: make(map[string]*list.Element), lru: list.New(), max: perShard}
+	}
+	if config.IdleTTL > 0 {
+		go s.expire(config.IdleTTL)
+	}
+	return s
+}
+
+func (s *Store[V]) shardFor(key string) *shard[V] {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+// GetOrCreate returns the value for key, creating it with create if the key
+// is new. created reports whether it did. create runs under the shard lock
+// and should be cheap.
+func (s *Store[V]) GetOrCreate(key string, create func() V) (value V, created bool) {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+
+	if el, ok := sh.items[key]; ok {
+		e := el.Value.(*entry[V])
+		e.lastUsed = time.Now()
+		sh.lru.MoveToFront(el)
+		sh.mu.Unlock()
+		return e.value, false
+	}
+
+	value = create()
+	sh.items[key] = sh.lru.PushFront(&entry[V]{key: key, value: value, lastUsed: time.Now()})
+	evicted := sh.trim()
+	sh.mu.Unlock()
+
+	s.evicted(evicted)
+	return value, true
+}
+
+// Set stores value under key, replacing any value already there
+func (s *Store[V]) Set(key string, value V) {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+
+	var evicted []*entry[V]
+	if el, ok := sh.items[key]; ok {
+		evicted = append(evicted, sh.lru.Remove(el).(*entry[V]))
+	}
+	sh.items[key] = sh.lru.PushFront(&entry[V]{key: key, value: value, lastUsed: time.Now()})
+	evicted = append(evicted, sh.trim()...)
+	sh.mu.Unlock()
+
+	s.evicted(evicted)
+}
+
+// Delete removes key and returns its value
+func (s *Store[V]) Delete(key string) (value V, found bool) {
+	sh := s.shardFor(key)
+	sh.mu.Lock()
+
+	el, ok := sh.items[key]
+	if !ok {
+		sh.mu.Unlock()
+		return value, false
+	}
+	e := sh.lru.Remove(el).(*entry[V])
+	delete(sh.items, key)
+	sh.mu.Unlock()
+
+	s.evicted([]*entry[V]{e})
+	return e.value, true
+}
+
+// Range calls fn for every key. It works on a copy of each shard, so fn may
+// use the store and sees keys added during the walk or not.
+func (s *Store[V]) Range(fn func(key string, value V)) {
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		entries := make([]entry[V], 0, sh.lru.Len())
+		for el := sh.lru.Front(); el != nil; el = el.Next() {
+			entries = append(entries, *el.Value.(*entry[V]))
+		}
+		sh.mu.Unlock()
+
+		for _, e := range entries {
+			fn(e.key, e.value)
+		}
+	}
+}
+
+// Len counts the keys across all shards
+func (s *Store[V]) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		n += sh.lru.Len()
+		sh.mu.Unlock()
+	}
+	return n
+}
+
+// trim drops least recently used keys until the shard fits, lock held
+func (sh *shard[V]) trim() []*entry[V] {
+	var evicted []*entry[V]
+	for sh.max > 0 && sh.lru.Len() > sh.max {
+		e := sh.lru.Remove(sh.lru.Back()).(*entry[V])
+		delete(sh.items, e.key)
+		evicted = append(evicted, e)
+	}
+	return evicted
+}
+
+func (s *Store[V]) evicted(entries []*entry[V]) {
+	if s.onEvict == nil {
+		return
+	}
+	for _, e := range entries {
+		s.onEvict(e.key, e.value)
+	}
+}
+
+// expire drops keys idle for longer than ttl. The list is in order of use,
+// so each shard is only walked from the back up to the first fresh key.
+func (s *Store[V]) expire(ttl time.Duration) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic for keystore expire: %v", r)
+			go s.expire(ttl)
+		}
+	}()
+
+	ticker := time.NewTicker(max(ttl/4, time.Second))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-ttl)
+		for _, sh := range s.shards {
+			var expired []*entry[V]
+			sh.mu.Lock()
+			for el := sh.lru.Back(); el != nil; el = sh.lru.Back() {
+				e := el.Value.(*entry[V])
+				if e.lastUsed.After(cutoff) {
+					break
+				}
+				sh.lru.Remove(el)
+				delete(sh.items, e.key)
+				expired = append(expired, e)
+			}
+			sh.mu.Unlock()
+			s.evicted(expired)
+		}
+	}
+}
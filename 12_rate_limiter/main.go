@@ -1,15 +1,31 @@
 package main
 
 import (
+	"crypto/subtle"
+	"log"
 	"net/http"
+	"os"
+	"time"
 
 	"github.com/AVVKavvk/rate_limiter/middlewares"
+	"github.com/AVVKavvk/rate_limiter/policy"
+	"github.com/AVVKavvk/rate_limiter/redisClient"
 	"github.com/labstack/echo/v4"
 	echoMiddleware "github.com/labstack/echo/v4/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
 
+	// REDIS_ADDR makes token bucket, fixed window and sliding window share
+	// one budget across instances; without it, or if Redis is down at
+	// startup, every instance limits in memory
+	if addr := os.Getenv("REDIS_ADDR"); addr != "" {
+		if err := redisClient.InitRedisClient(addr, os.Getenv("REDIS_PASSWORD")); err != nil {
+			log.Printf("could not connect to Redis, using in-memory rate limits: %v", err)
+		}
+	}
+
 	e := echo.New()
 
 	e.Use(echoMiddleware.RequestID())
@@ -17,6 +33,21 @@ func main() {
 	e.Use(echoMiddleware.RequestLogger())
 	e.Use(echoMiddleware.Recover())
 
+	// POLICY_FILE limits every route by the rules in a YAML file, on top of
+	// the fixed per-route limits below; the file is reloaded when it changes
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		engine, err := policy.NewEngine(path)
+		if err != nil {
+			log.Fatalf("could not load rate limit policies: %v", err)
+		}
+		interval, err := time.ParseDuration(os.Getenv("POLICY_RELOAD_INTERVAL"))
+		if err != nil || interval <= 0 {
+			interval = 5 * time.Second
+		}
+		go engine.Watch(interval)
+		e.Use(middlewares.PolicyMiddleware(engine))
+	}
+
 	// rate limiting middleware
 
 	// token bucket
@@ -55,6 +86,27 @@ func main() {
 		})
 	}, middlewares.LeakyBucketMiddleware)
 
+	// leaky bucket that queues requests instead of dropping them
+	e.GET("/users/leaky-bucket-queue", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Success",
+			"algo":    "leaky-bucket-queue",
+			"data": []map[string]interface{}{
+				{
+					"id":   1,
+					"name": "John Doe",
+				},
+				{
+					"id":   2,
+					"name": "Maria Jones",
+				},
+			},
+		})
+	}, middlewares.LeakyBucketQueueMiddleware)
+
+	// queue depth and wait times of the leaky bucket queue
+	e.GET("/stats/leaky-bucket-queue", middlewares.LeakyBucketQueueStats)
+
 	// fixed window
 	e.GET("/users/fixed-window", func(ctx echo.Context) error {
 		return ctx.JSON(http.StatusOK, map[string]interface{}{
@@ -91,5 +143,83 @@ func main() {
 		})
 	}, middlewares.SlidingWindowCounterMiddleware)
 
-	e.Logger.Fatal(e.Start(":8080"))
+	// sliding window log
+	e.GET("/users/sliding-window-log", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Success",
+			"algo":    "sliding-window-log",
+			"data": []map[string]interface{}{
+				{
+					"id":   1,
+					"name": "John Doe",
+				},
+				{
+					"id":   2,
+					"name": "Maria Jones",
+				},
+			},
+		})
+	}, middlewares.SlidingWindowLogMiddleware)
+
+	// limited only by the policy file
+	e.GET("/users/policy", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Success",
+			"algo":    "policy",
+			"data": []map[string]interface{}{
+				{
+					"id":   1,
+					"name": "John Doe",
+				},
+				{
+					"id":   2,
+					"name": "Maria Jones",
+				},
+			},
+		})
+	})
+
+	// adaptive concurrency
+	e.GET("/users/adaptive-concurrency", func(ctx echo.Context) error {
+		return ctx.JSON(http.StatusOK, map[string]interface{}{
+			"message": "Success",
+			"algo":    "adaptive-concurrency",
+			"data": []map[string]interface{}{
+				{
+					"id":   1,
+					"name": "John Doe",
+				},
+				{
+					"id":   2,
+					"name": "Maria Jones",
+				},
+			},
+		})
+	}, middlewares.AdaptiveConcurrencyMiddleware)
+
+	// current concurrency limit and latencies
+	e.GET("/stats/adaptive-concurrency", middlewares.AdaptiveConcurrencyStats)
+
+	// allowed and rejected requests per algorithm and route
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
+
+	// inspect and reset the limiter of a client. The list shows raw client
+	// keys and a reset lifts a client's limit, so without ADMIN_TOKEN the
+	// routes are not registered at all
+	if token := os.Getenv("ADMIN_TOKEN"); token != "" {
+		admin := e.Group("/admin", echoMiddleware.KeyAuth(func(key string, ctx echo.Context) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1, nil
+		}))
+		admin.GET("/limiters", middlewares.ListLimiters)
+		admin.DELETE("/limiters/:algorithm", middlewares.ResetLimiter)
+	} else {
+		log.Printf("ADMIN_TOKEN is not set, the /admin endpoints are disabled")
+	}
+
+	// PORT lets several instances run on one host
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+	e.Logger.Fatal(e.Start(":" + port))
 }
@@ -0,0 +1,101 @@
+package middlewares
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	AdaptiveConcurrencyMiddleware echo.MiddlewareFunc
+	adaptiveLimiter               *rate_limiter.AdaptiveLimiter
+)
+
+// addAdaptiveConcurrencyLimiter protects the handler as a whole, not per
+// client: it is the backend's capacity that is being measured
+func addAdaptiveConcurrencyLimiter(limiter *rate_limiter.AdaptiveLimiter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+
+			release, ok := limiter.Acquire()
+			recordDecision(ctx, "adaptive_concurrency", ok)
+			if !ok {
+				// the server is busy, not the client too eager: 503, and
+				// a second is plenty for the in-flight requests to finish
+				ctx.Response().Header().Set("Retry-After", "1")
+				return ctx.JSON(http.StatusServiceUnavailable, map[string]string{
+					"error": "Server is overloaded. Please try again later.",
+				})
+			}
+
+			// a panicking handler must still give its slot back, or enough
+			// panics would leave the limiter full for good. The outer
+			// Recover answers the request; count it as dropped.
+			returned := false
+			defer func() {
+				if !returned {
+					release(true)
+				}
+			}()
+
+			err := next(ctx)
+			returned = true
+
+			// timeouts and 503/504 from further down mean the backend is
+			// overloaded; other errors say nothing about load
+			status := ctx.Response().Status
+			var httpErr *echo.HTTPError
+			if errors.As(err, &httpErr) {
+				status = httpErr.Code
+			}
+			release(errors.Is(err, context.DeadlineExceeded) ||
+				status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout)
+
+			return err
+		}
+	}
+}
+
+// AdaptiveConcurrencyStats shows the current limit and what it is based on
+func AdaptiveConcurrencyStats(ctx echo.Context) error {
+	stats := adaptiveLimiter.Stats()
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"limit":     stats.Limit,
+		"inFlight":  stats.InFlight,
+		"minRttMs":  float64(stats.MinRTT) / float64(time.Millisecond),
+		"lastRttMs": float64(stats.LastRTT) / float64(time.Millisecond),
+		"accepted":  stats.Accepted,
+		"rejected":  stats.Rejected,
+	})
+}
+
+// adaptiveAlgorithm picks the limit algorithm from ADAPTIVE_ALGORITHM:
+// gradient (default), vegas or aimd
+func adaptiveAlgorithm(name string) rate_limiter.LimitAlgorithm {
+	switch name {
+	case "vegas":
+		// grow while fewer than 3 requests queue in the backend, shrink above 6
+		return rate_limiter.Vegas{Alpha: 3, Beta: 6}
+	case "aimd":
+		// +1 per sample window, halve on a drop or when latency passes 500ms
+		return rate_limiter.AIMD{Increase: 1, Backoff: 0.5, Timeout: 500 * time.Millisecond}
+	case "", "gradient":
+	default:
+		log.Printf("unknown ADAPTIVE_ALGORITHM %q, using gradient", name)
+	}
+	// accept up to 1.5x the no-load latency, take 20% of each estimate
+	return rate_limiter.Gradient{Tolerance: 1.5, Smoothing: 0.2}
+}
+
+func init() {
+	// Start at 20 requests in flight, never below 5 or above 1000
+	adaptiveLimiter = rate_limiter.GetAdaptiveLimiter(adaptiveAlgorithm(os.Getenv("ADAPTIVE_ALGORITHM")), 20, 5, 1000)
+
+	AdaptiveConcurrencyMiddleware = addAdaptiveConcurrencyLimiter(adaptiveLimiter)
+}
@@ -0,0 +1,134 @@
+package middlewares
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/AVVKavvk/rate_limiter/keystore"
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/labstack/echo/v4"
+)
+
+// quotaReporter is anything the admin endpoints can show the state of
+type quotaReporter interface {
+	Quota() rate_limiter.Quota
+}
+
+// resetter is implemented by limiters whose state lives outside the map,
+// in Redis, and has to be cleared there as well
+type resetter interface {
+	Reset() error
+}
+
+// limiterStore is one middleware's per-client limiters as the admin
+// endpoints see them
+type limiterStore interface {
+	quotas() map[string]rate_limiter.Quota
+	reset(key string) (bool, error)
+	len() int
+}
+
+// storeView adapts a middleware's key store
+type storeView[V quotaReporter] struct {
+	store *keystore.Store[V]
+}
+
+func (v storeView[V]) quotas() map[string]rate_limiter.Quota {
+	quotas := make(map[string]rate_limiter.Quota)
+	v.store.Range(func(key string, limiter V) {
+		quotas[key] = limiter.Quota()
+	})
+	return quotas
+}
+
+func (v storeView[V]) len() int {
+	return v.store.Len()
+}
+
+// reset forgets a client, its next request starts with a fresh limiter
+func (v storeView[V]) reset(key string) (bool, error) {
+	limiter, exists := v.store.Delete(key)
+	if !exists {
+		return false, nil
+	}
+	if r, ok := any(limiter).(resetter); ok {
+		return true, r.Reset()
+	}
+	return true, nil
+}
+
+// limiterStores lists every middleware's clients by algorithm
+var limiterStores = map[string]limiterStore{
+	"token_bucket":           storeView[rate_limiter.Limiter]{tokenBuckets},
+	"leaky_bucket":           storeView[*rate_limiter.LeakyBucket]{leakyBuckets},
+	"leaky_bucket_queue":     storeView[*rate_limiter.LeakyQueue]{leakyBucketQueues},
+	"fixed_window":           storeView[rate_limiter.Limiter]{fixedWindows},
+	"sliding_window_counter": storeView[rate_limiter.Limiter]{slidingWindowCounterBuckets},
+	"sliding_window_log":     storeView[*rate_limiter.SlidingWindowLog]{slidingWindowLogs},
+	"policy":                 storeView[*policyLimiter]{policyLimiters},
+}
+
+// limiterState is one client's entry in the admin listing
+type limiterState struct {
+	Key          string  `json:"key"`
+	Limit        int     `json:"limit"`
+	Remaining    int     `json:"remaining"`
+	ResetMs      float64 `json:"resetMs"`
+	RetryAfterMs float64 `json:"retryAfterMs"`
+}
+
+// ListLimiters shows every active client per algorithm with its remaining
+// tokens or requests. ?algorithm= narrows it to one.
+func ListLimiters(ctx echo.Context) error {
+	algorithm := ctx.QueryParam("algorithm")
+	if algorithm != "" {
+		if _, ok := limiterStores[algorithm]; !ok {
+			return ctx.JSON(http.StatusBadRequest, map[string]string{"message": "unknown algorithm " + algorithm})
+		}
+	}
+
+	result := make(map[string][]limiterState)
+	for name, store := range limiterStores {
+		if algorithm != "" && name != algorithm {
+			continue
+		}
+		states := make([]limiterState, 0)
+		for key, quota := range store.quotas() {
+			states = append(states, limiterState{
+				Key:          key,
+				Limit:        quota.Limit,
+				Remaining:    quota.Remaining,
+				ResetMs:      float64(quota.Reset) / float64(time.Millisecond),
+				RetryAfterMs: float64(quota.RetryAfter) / float64(time.Millisecond),
+			})
+		}
+		sort.Slice(states, func(i, j int) bool { return states[i].Key < states[j].Key })
+		result[name] = states
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+// ResetLimiter forgets one client of one algorithm, given as
+// /admin/limiters/:algorithm?key=<key>. Keys are query parameters because
+// IPv6 addresses and policy keys contain colons.
+func ResetLimiter(ctx echo.Context) error {
+	algorithm := ctx.Param("algorithm")
+	store, ok := limiterStores[algorithm]
+	if !ok {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"message": "unknown algorithm " + algorithm})
+	}
+	key := ctx.QueryParam("key")
+	if key == "" {
+		return ctx.JSON(http.StatusBadRequest, map[string]string{"message": "key is required"})
+	}
+
+	found, err := store.reset(key)
+	if err != nil {
+		return ctx.JSON(http.StatusInternalServerError, map[string]string{"message": err.Error()})
+	}
+	if !found {
+		return ctx.JSON(http.StatusNotFound, map[string]string{"message": "no limiter for " + key})
+	}
+	return ctx.JSON(http.StatusOK, map[string]string{"message": "reset " + algorithm + " for " + key})
+}
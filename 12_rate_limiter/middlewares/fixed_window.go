@@ -2,17 +2,16 @@ package middlewares
 
 import (
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/AVVKavvk/rate_limiter/redisClient"
 	"github.com/labstack/echo/v4"
 )
 
 var (
 	FixedWindowMiddleware echo.MiddlewareFunc
-	fixedWindows          = make(map[string]*rate_limiter.FixedWindow)
-	fixedWindowsMutex     sync.Mutex
+	fixedWindows          = newLimiterStore[rate_limiter.Limiter]()
 )
 
 func addFixedWindowRateLimiter(limit int, window time.Duration) echo.MiddlewareFunc {
@@ -22,19 +21,19 @@ func addFixedWindowRateLimiter(limit int, window time.Duration) echo.MiddlewareF
 			// Get client identifier (IP address)
 			clientIP := ctx.RealIP()
 
-			fixedWindowsMutex.Lock()
-			defer fixedWindowsMutex.Unlock()
-
 			// Get or create bucket for this client
-			bucket, exists := fixedWindows[clientIP]
-
-			if !exists {
-				bucket = rate_limiter.GetFixedWindow(limit, window)
-				fixedWindows[clientIP] = bucket
-			}
+			bucket, _ := fixedWindows.GetOrCreate(clientIP, func() rate_limiter.Limiter {
+				if useRedis() {
+					return rate_limiter.GetRedisFixedWindow(redisClient.GetRedisClient(), redisKey("fixed_window", clientIP), limit, window)
+				}
+				return rate_limiter.GetFixedWindow(limit, window)
+			})
 
 			// Check if request is allowed
-			if !bucket.Allow() {
+			allowed := bucket.Allow()
+			setRateLimitHeaders(ctx, bucket.Quota())
+			recordDecision(ctx, "fixed_window", allowed)
+			if !allowed {
 				return ctx.JSON(http.StatusTooManyRequests, map[string]string{
 					"error": "Rate limit exceeded. Please try again later.",
 				})
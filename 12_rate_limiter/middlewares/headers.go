@@ -0,0 +1,25 @@
+package middlewares
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/labstack/echo/v4"
+)
+
+// setRateLimitHeaders tells the client where it stands, following the IETF
+// RateLimit header fields draft. Durations are whole seconds, rounded up so
+// a client that waits that long is not rejected again.
+func setRateLimitHeaders(ctx echo.Context, quota rate_limiter.Quota) {
+	header := ctx.Response().Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(quota.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(quota.Remaining))
+	header.Set("RateLimit-Reset", seconds(quota.Reset))
+	header.Set("Retry-After", seconds(quota.RetryAfter))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -2,7 +2,6 @@ package middlewares
 
 import (
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/AVVKavvk/rate_limiter/rate_limiter"
@@ -11,8 +10,7 @@ import (
 
 var (
 	LeakyBucketMiddleware echo.MiddlewareFunc
-	leakyBuckets          = make(map[string]*rate_limiter.LeakyBucket)
-	leakyBucketsMutex     sync.Mutex
+	leakyBuckets          = newLimiterStore[*rate_limiter.LeakyBucket]()
 )
 
 func addLeakyBucketRateLimiter(capacity int, processRate time.Duration) echo.MiddlewareFunc {
@@ -23,18 +21,16 @@ func addLeakyBucketRateLimiter(capacity int, processRate time.Duration) echo.Mid
 			// Get client identifier (IP address)
 			clientIP := ctx.RealIP()
 
-			leakyBucketsMutex.Lock()
-			defer leakyBucketsMutex.Unlock()
-
 			// Get or create bucket for this client
-			bucket, exists := leakyBuckets[clientIP]
+			bucket, _ := leakyBuckets.GetOrCreate(clientIP, func() *rate_limiter.LeakyBucket {
+				return rate_limiter.GetLeakyBucket(capacity, processRate)
+			})
 
-			if !exists {
-				bucket = rate_limiter.GetLeakyBucket(capacity, processRate)
-				leakyBuckets[clientIP] = bucket
-			}
 			// Check if request is allowed
-			if !bucket.Allow() {
+			allowed := bucket.Allow()
+			setRateLimitHeaders(ctx, bucket.Quota())
+			recordDecision(ctx, "leaky_bucket", allowed)
+			if !allowed {
 				return ctx.JSON(http.StatusTooManyRequests, map[string]string{
 					"error": "Rate limit exceeded. Please try again later.",
 				})
@@ -0,0 +1,100 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/labstack/echo/v4"
+)
+
+// statusClientClosedRequest is nginx's status for a client that hung up
+// before the response; nobody reads it, it only shows up in the logs
+const statusClientClosedRequest = 499
+
+var (
+	LeakyBucketQueueMiddleware echo.MiddlewareFunc
+	leakyBucketQueues          = newLimiterStore[*rate_limiter.LeakyQueue]()
+)
+
+func addLeakyBucketQueueRateLimiter(capacity int, processRate time.Duration, maxWait time.Duration) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+
+			// Get client identifier (IP address)
+			clientIP := ctx.RealIP()
+
+			// The store is only locked to find the queue, waiting happens
+			// outside it so clients do not queue behind each other
+			queue, _ := leakyBucketQueues.GetOrCreate(clientIP, func() *rate_limiter.LeakyQueue {
+				return rate_limiter.GetLeakyQueue(capacity, processRate, maxWait)
+			})
+
+			// the request context is cancelled when the client disconnects
+			err := queue.Wait(ctx.Request().Context())
+			setRateLimitHeaders(ctx, queue.Quota())
+
+			if ctx.Request().Context().Err() == nil {
+				recordDecision(ctx, "leaky_bucket_queue", err == nil)
+			}
+
+			switch {
+			case err == nil:
+				return next(ctx)
+			case errors.Is(err, rate_limiter.ErrQueueFull), errors.Is(err, rate_limiter.ErrQueueWait):
+				return ctx.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "Rate limit exceeded. Please try again later.",
+				})
+			default:
+				return ctx.NoContent(statusClientClosedRequest)
+			}
+		}
+	}
+}
+
+// LeakyBucketQueueStats sums the queue metrics of every client: how many
+// requests wait now, what happened to the rest and how long they waited
+func LeakyBucketQueueStats(ctx echo.Context) error {
+	queues := make(map[string]*rate_limiter.LeakyQueue)
+	leakyBucketQueues.Range(func(ip string, queue *rate_limiter.LeakyQueue) {
+		queues[ip] = queue
+	})
+
+	var total rate_limiter.LeakyQueueStats
+	depths := make(map[string]int)
+	for ip, queue := range queues {
+		stats := queue.Stats()
+		if stats.Depth > 0 {
+			depths[ip] = stats.Depth
+		}
+		total.Depth += stats.Depth
+		total.Admitted += stats.Admitted
+		total.Rejected += stats.Rejected
+		total.Cancelled += stats.Cancelled
+		total.TotalWait += stats.TotalWait
+		total.MaxWait = max(total.MaxWait, stats.MaxWait)
+	}
+
+	// averaged over every admitted request, those let straight through count as 0
+	avgWait := time.Duration(0)
+	if total.Admitted > 0 {
+		avgWait = total.TotalWait / time.Duration(total.Admitted)
+	}
+
+	return ctx.JSON(http.StatusOK, map[string]interface{}{
+		"clients":    len(queues),
+		"queueDepth": total.Depth,
+		"depthByIP":  depths,
+		"admitted":   total.Admitted,
+		"rejected":   total.Rejected,
+		"cancelled":  total.Cancelled,
+		"avgWaitMs":  float64(avgWait) / float64(time.Millisecond),
+		"maxWaitMs":  float64(total.MaxWait) / float64(time.Millisecond),
+	})
+}
+
+func init() {
+	// 5 requests/second, up to 10 waiting and none longer than 2 seconds
+	LeakyBucketQueueMiddleware = addLeakyBucketQueueRateLimiter(10, 200*time.Millisecond, 2*time.Second)
+}
@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// requestsTotal counts rate limit decisions. route is the registered route
+// pattern, not the request path, so the number of series stays bounded.
+var requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "rate_limiter_requests_total",
+	Help: "Requests seen by the rate limiters, by algorithm, route and result (allowed or rejected).",
+}, []string{"algorithm", "route", "result"})
+
+func init() {
+	// how close each store is to LIMITER_MAX_KEYS
+	for algorithm, store := range limiterStores {
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "rate_limiter_keys",
+			Help:        "Clients with a limiter, by algorithm.",
+			ConstLabels: prometheus.Labels{"algorithm": algorithm},
+		}, func() float64 { return float64(store.len()) })
+	}
+}
+
+func recordDecision(ctx echo.Context, algorithm string, allowed bool) {
+	result := "allowed"
+	if !allowed {
+		result = "rejected"
+	}
+	requestsTotal.WithLabelValues(algorithm, ctx.Path(), result).Inc()
+}
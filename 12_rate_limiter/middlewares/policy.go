@@ -0,0 +1,119 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/AVVKavvk/rate_limiter/policy"
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/AVVKavvk/rate_limiter/redisClient"
+	"github.com/labstack/echo/v4"
+)
+
+// policyLimiter remembers the limit a limiter was built with, so a reload
+// that changes it gets a fresh limiter
+type policyLimiter struct {
+	limit   policy.Limit
+	limiter rate_limiter.Limiter
+}
+
+func (p *policyLimiter) Quota() rate_limiter.Quota {
+	return p.limiter.Quota()
+}
+
+// Reset clears shared state when the rule's limiter keeps it in Redis
+func (p *policyLimiter) Reset() error {
+	if r, ok := p.limiter.(resetter); ok {
+		return r.Reset()
+	}
+	return nil
+}
+
+// Stop ends the goroutines of the rule's limiter
+func (p *policyLimiter) Stop() {
+	stopLimiter("", p.limiter)
+}
+
+var policyLimiters = newLimiterStore[*policyLimiter]()
+
+// PolicyMiddleware limits each request by the first matching rule of
+// engine. Requests no rule matches pass through.
+func PolicyMiddleware(engine *policy.Engine) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			decision, ok := engine.Match(policy.Request{
+				Method: ctx.Request().Method,
+				Route:  ctx.Path(),
+				Path:   ctx.Request().URL.Path,
+				IP:     ctx.RealIP(),
+				Header: ctx.Request().Header,
+			})
+			if !ok {
+				return next(ctx)
+			}
+
+			// one limiter per rule, tier and client
+			key := decision.Rule + ":" + decision.Tier + ":" + decision.Key
+
+			newEntry := func() *policyLimiter {
+				return &policyLimiter{limit: decision.Limit, limiter: newPolicyLimiter(key, decision.Limit)}
+			}
+			entry, _ := policyLimiters.GetOrCreate(key, newEntry)
+			if entry.limit != decision.Limit {
+				// the rule changed on reload, the replaced limiter is stopped
+				entry = newEntry()
+				policyLimiters.Set(key, entry)
+			}
+
+			allowed := true
+			if queue, ok := entry.limiter.(*rate_limiter.LeakyQueue); ok {
+				err := queue.Wait(ctx.Request().Context())
+				if err != nil && ctx.Request().Context().Err() != nil {
+					return ctx.NoContent(statusClientClosedRequest)
+				}
+				allowed = err == nil
+			} else {
+				allowed = entry.limiter.Allow()
+			}
+			setRateLimitHeaders(ctx, entry.limiter.Quota())
+			recordDecision(ctx, "policy:"+decision.Limit.Algorithm, allowed)
+			if !allowed {
+				return ctx.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "Rate limit exceeded. Please try again later.",
+					"rule":  decision.Rule,
+					"tier":  decision.Tier,
+				})
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+// newPolicyLimiter builds the limiter a rule asks for, shared through Redis
+// when the algorithm supports it
+func newPolicyLimiter(key string, limit policy.Limit) rate_limiter.Limiter {
+	switch limit.Algorithm {
+	case policy.TokenBucket:
+		if useRedis() {
+			return rate_limiter.GetRedisTokenBucket(redisClient.GetRedisClient(), redisKey("policy:token_bucket", key), limit.Capacity, limit.RefillPerMinute)
+		}
+		return rate_limiter.GetNewTokenBucket(limit.Capacity, limit.RefillPerMinute)
+	case policy.LeakyBucket:
+		if limit.MaxWait > 0 {
+			return rate_limiter.GetLeakyQueue(limit.Capacity, limit.ProcessRate, limit.MaxWait)
+		}
+		return rate_limiter.GetLeakyBucket(limit.Capacity, limit.ProcessRate)
+	case policy.FixedWindow:
+		if useRedis() {
+			return rate_limiter.GetRedisFixedWindow(redisClient.GetRedisClient(), redisKey("policy:fixed_window", key), limit.Limit, limit.Window)
+		}
+		return rate_limiter.GetFixedWindow(limit.Limit, limit.Window)
+	case policy.SlidingWindowCounter:
+		if useRedis() {
+			return rate_limiter.GetRedisSlidingWindowCounter(redisClient.GetRedisClient(), redisKey("policy:sliding_window_counter", key), limit.Limit, limit.Window)
+		}
+		return rate_limiter.GetSlidingWindowCounter(limit.Limit, limit.Window)
+	default:
+		return rate_limiter.GetSlidingWindowLog(limit.Limit, limit.Window)
+	}
+}
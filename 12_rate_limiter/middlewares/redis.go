@@ -0,0 +1,14 @@
+package middlewares
+
+import "github.com/AVVKavvk/rate_limiter/redisClient"
+
+// redisKey is where the shared state of one algorithm and client lives
+func redisKey(algo string, clientIP string) string {
+	return "rate_limiter:" + algo + ":" + clientIP
+}
+
+// useRedis reports whether new limiters should share their state through
+// Redis. Without REDIS_ADDR every instance limits on its own.
+func useRedis() bool {
+	return redisClient.GetRedisClient() != nil
+}
@@ -2,17 +2,16 @@ package middlewares
 
 import (
 	"net/http"
-	"sync"
 	"time"
 
 	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/AVVKavvk/rate_limiter/redisClient"
 	"github.com/labstack/echo/v4"
 )
 
 var (
 	SlidingWindowCounterMiddleware echo.MiddlewareFunc
-	slidingWindowCounterBuckets    = make(map[string]*rate_limiter.SlidingWindowCounter)
-	slidingWindowCounterMutex      sync.Mutex
+	slidingWindowCounterBuckets    = newLimiterStore[rate_limiter.Limiter]()
 )
 
 func addSlidingWindowCounterRateLimiter(limit int, windowSize time.Duration) echo.MiddlewareFunc {
@@ -23,19 +22,19 @@ func addSlidingWindowCounterRateLimiter(limit int, windowSize time.Duration) ech
 			// Get client identifier (IP address)
 			clientIP := ctx.RealIP()
 
-			slidingWindowCounterMutex.Lock()
-			defer slidingWindowCounterMutex.Unlock()
-
 			// Get or create bucket for this client
-			bucket, exists := slidingWindowCounterBuckets[clientIP]
-
-			if !exists {
-				bucket = rate_limiter.GetSlidingWindowCounter(limit, windowSize)
-				slidingWindowCounterBuckets[clientIP] = bucket
-			}
+			bucket, _ := slidingWindowCounterBuckets.GetOrCreate(clientIP, func() rate_limiter.Limiter {
+				if useRedis() {
+					return rate_limiter.GetRedisSlidingWindowCounter(redisClient.GetRedisClient(), redisKey("sliding_window_counter", clientIP), limit, windowSize)
+				}
+				return rate_limiter.GetSlidingWindowCounter(limit, windowSize)
+			})
 
 			// Check if request is allowed
-			if !bucket.Allow() {
+			allowed := bucket.Allow()
+			setRateLimitHeaders(ctx, bucket.Quota())
+			recordDecision(ctx, "sliding_window_counter", allowed)
+			if !allowed {
 				return ctx.JSON(http.StatusTooManyRequests, map[string]string{
 					"error": "Rate limit exceeded. Please try again later.",
 				})
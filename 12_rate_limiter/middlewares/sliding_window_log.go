@@ -0,0 +1,47 @@
+package middlewares
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	SlidingWindowLogMiddleware echo.MiddlewareFunc
+	slidingWindowLogs          = newLimiterStore[*rate_limiter.SlidingWindowLog]()
+)
+
+func addSlidingWindowLogRateLimiter(limit int, windowSize time.Duration) echo.MiddlewareFunc {
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+
+			// Get client identifier (IP address)
+			clientIP := ctx.RealIP()
+
+			// Get or create log for this client
+			log, _ := slidingWindowLogs.GetOrCreate(clientIP, func() *rate_limiter.SlidingWindowLog {
+				return rate_limiter.GetSlidingWindowLog(limit, windowSize)
+			})
+
+			// Check if request is allowed
+			allowed := log.Allow()
+			setRateLimitHeaders(ctx, log.Quota())
+			recordDecision(ctx, "sliding_window_log", allowed)
+			if !allowed {
+				return ctx.JSON(http.StatusTooManyRequests, map[string]string{
+					"error": "Rate limit exceeded. Please try again later.",
+				})
+			}
+
+			return next(ctx)
+		}
+	}
+}
+
+func init() {
+	//  5 requests per 10 seconds, same as the sliding window counter
+	SlidingWindowLogMiddleware = addSlidingWindowLogRateLimiter(5, 10*time.Second)
+}
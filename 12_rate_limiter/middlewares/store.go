@@ -0,0 +1,45 @@
+package middlewares
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/AVVKavvk/rate_limiter/keystore"
+)
+
+// limiterStoreConfig applies to every middleware's store:
+// LIMITER_MAX_KEYS clients per middleware (100000), spread over
+// LIMITER_SHARDS locks (32), dropped after LIMITER_IDLE_TTL unused (1h)
+var limiterStoreConfig = keystore.Config{
+	Shards:     envInt("LIMITER_SHARDS", 32),
+	MaxEntries: envInt("LIMITER_MAX_KEYS", 100000),
+	IdleTTL:    envDuration("LIMITER_IDLE_TTL", time.Hour),
+}
+
+// newLimiterStore holds one middleware's per-client limiters
+func newLimiterStore[V any]() *keystore.Store[V] {
+	return keystore.New(limiterStoreConfig, stopLimiter[V])
+}
+
+// stopLimiter ends the goroutines of a limiter that left its store.
+// Shared state in Redis is kept; another instance may still use it.
+func stopLimiter[V any](key string, limiter V) {
+	if s, ok := any(limiter).(interface{ Stop() }); ok {
+		s.Stop()
+	}
+}
+
+func envInt(key string, fallback int) int {
+	if val, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return val
+	}
+	return fallback
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	if val, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return val
+	}
+	return fallback
+}
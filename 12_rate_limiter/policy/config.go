@@ -0,0 +1,184 @@
+// Package policy decides which rate limit applies to a request. Rules are
+// loaded from YAML and matched in order on route, method, API key, JWT user
+// and header values; each rule carries one limit per tier.
+package policy
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Algorithms a Limit can use
+const (
+	TokenBucket          = "token_bucket"
+	LeakyBucket          = "leaky_bucket"
+	FixedWindow          = "fixed_window"
+	SlidingWindowCounter = "sliding_window_counter"
+	SlidingWindowLog     = "sliding_window_log"
+)
+
+// Tiers every config knows, more can be added through api_keys and rules
+const (
+	TierFree       = "free"
+	TierPro        = "pro"
+	TierEnterprise = "enterprise"
+)
+
+type Config struct {
+	// APIKeyHeader carries the API key, X-API-Key by default
+	APIKeyHeader string `yaml:"api_key_header"`
+	// DefaultTier is used for anonymous callers and keys without a tier
+	DefaultTier string            `yaml:"default_tier"`
+	JWT         JWTConfig         `yaml:"jwt"`
+	APIKeys     map[string]APIKey `yaml:"api_keys"`
+	Rules       []Rule            `yaml:"rules"`
+}
+
+// minJWTSecret is the shortest HS256 secret accepted. Anyone who guesses
+// the secret can sign a token claiming any tier, so a placeholder like
+// "change-me" is refused rather than silently trusted.
+const minJWTSecret = 32
+
+// JWTConfig turns on reading the user and tier from an HS256 bearer token.
+// Without a secret tokens are ignored, they cannot be trusted.
+type JWTConfig struct {
+	Secret    string `yaml:"secret"`
+	UserClaim string `yaml:"user_claim"`
+	TierClaim string `yaml:"tier_claim"`
+}
+
+type APIKey struct {
+	Tier string `yaml:"tier"`
+	// User is the identity the key stands for, used by key: user
+	User string `yaml:"user"`
+}
+
+type Rule struct {
+	Name string `yaml:"name"`
+	// Match conditions, all given ones must hold; an empty list matches anything
+	Routes  []string          `yaml:"routes"` // route pattern like /users/:id, or a path glob like /users/*
+	Methods []string          `yaml:"methods"`
+	APIKeys []string          `yaml:"api_keys"`
+	Users   []string          `yaml:"users"`
+	Headers map[string]string `yaml:"headers"`
+	// Key is what a client is counted by: ip (default), api_key, user or
+	// header:<Name>. A request without it is counted by IP. Clients set their
+	// own headers, so header:<Name> is only safe for a header a trusted proxy
+	// sets; otherwise a new value per request escapes the limit.
+	Key string `yaml:"key"`
+	// Limits per tier; a tier without an entry gets the default tier's
+	Limits map[string]Limit `yaml:"limits"`
+}
+
+// Limit is one algorithm with its parameters
+type Limit struct {
+	Algorithm string `yaml:"algorithm"`
+	// token_bucket: Capacity tokens, refilled at RefillPerMinute
+	// leaky_bucket: Capacity queued requests, one leaks every ProcessRate;
+	// with MaxWait requests wait for their turn instead of being dropped
+	Capacity        int           `yaml:"capacity"`
+	RefillPerMinute float64       `yaml:"refill_per_minute"`
+	ProcessRate     time.Duration `yaml:"process_rate"`
+	MaxWait         time.Duration `yaml:"max_wait"`
+	// fixed_window, sliding_window_counter, sliding_window_log: Limit
+	// requests per Window
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+}
+
+// Load reads and validates a policy file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	if c.APIKeyHeader == "" {
+		c.APIKeyHeader = "X-API-Key"
+	}
+	if c.DefaultTier == "" {
+		c.DefaultTier = TierFree
+	}
+	if c.JWT.UserClaim == "" {
+		c.JWT.UserClaim = "sub"
+	}
+	if c.JWT.TierClaim == "" {
+		c.JWT.TierClaim = "tier"
+	}
+	if c.JWT.Secret != "" && len(c.JWT.Secret) < minJWTSecret {
+		return fmt.Errorf("jwt.secret must be at least %d bytes, leave it empty to ignore tokens", minJWTSecret)
+	}
+
+	names := make(map[string]bool, len(c.Rules))
+	for i := range c.Rules {
+		rule := &c.Rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d has no name", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %q defined twice", rule.Name)
+		}
+		names[rule.Name] = true
+
+		if rule.Key == "" {
+			rule.Key = "ip"
+		}
+		if !validKey(rule.Key) {
+			return fmt.Errorf("rule %q: key %q, use ip, api_key, user or header:<Name>", rule.Name, rule.Key)
+		}
+		if len(rule.Limits) == 0 {
+			return fmt.Errorf("rule %q has no limits", rule.Name)
+		}
+		for tier, limit := range rule.Limits {
+			if err := limit.validate(); err != nil {
+				return fmt.Errorf("rule %q tier %q: %w", rule.Name, tier, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validKey(key string) bool {
+	switch key {
+	case "ip", "api_key", "user":
+		return true
+	}
+	return len(key) > len("header:") && key[:len("header:")] == "header:"
+}
+
+func (l Limit) validate() error {
+	switch l.Algorithm {
+	case TokenBucket:
+		if l.Capacity <= 0 || l.RefillPerMinute <= 0 {
+			return fmt.Errorf("token_bucket needs capacity and refill_per_minute > 0")
+		}
+	case LeakyBucket:
+		if l.Capacity <= 0 || l.ProcessRate <= 0 {
+			return fmt.Errorf("leaky_bucket needs capacity and process_rate > 0")
+		}
+		if l.MaxWait < 0 {
+			return fmt.Errorf("leaky_bucket max_wait cannot be negative")
+		}
+	case FixedWindow, SlidingWindowCounter, SlidingWindowLog:
+		if l.Limit <= 0 || l.Window <= 0 {
+			return fmt.Errorf("%s needs limit and window > 0", l.Algorithm)
+		}
+	default:
+		return fmt.Errorf("unknown algorithm %q", l.Algorithm)
+	}
+	return nil
+}
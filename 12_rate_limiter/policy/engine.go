@@ -0,0 +1,229 @@
+package policy
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Request is what rules are matched against
+type Request struct {
+	Method string
+	// Route is the registered pattern, e.g. /users/:id, Path the actual path
+	Route  string
+	Path   string
+	IP     string
+	Header http.Header
+}
+
+// Decision is the limit a request falls under
+type Decision struct {
+	Rule string
+	Tier string
+	// Key identifies the client within the rule, e.g. api_key:k-123
+	Key   string
+	Limit Limit
+}
+
+// caller is who sent a request, as far as the config lets us tell
+type caller struct {
+	apiKey string
+	user   string
+	tier   string
+}
+
+// Engine holds the current config; Reload swaps it without blocking Match
+type Engine struct {
+	path   string
+	config atomic.Pointer[Config]
+}
+
+// NewEngine loads the policy file at path
+func NewEngine(path string) (*Engine, error) {
+	config, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	engine := &Engine{path: path}
+	engine.config.Store(config)
+	return engine, nil
+}
+
+// Reload reads the policy file again. A broken file leaves the current
+// rules in place.
+func (e *Engine) Reload() error {
+	config, err := Load(e.path)
+	if err != nil {
+		return err
+	}
+	e.config.Store(config)
+	return nil
+}
+
+// Watch reloads the policy file whenever its modification time or size
+// changes, checking every interval
+func (e *Engine) Watch(interval time.Duration) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Recovered from panic for policy watch: %v", r)
+			go e.Watch(interval)
+		}
+	}()
+
+	var modTime time.Time
+	var size int64
+	if info, err := os.Stat(e.path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		info, err := os.Stat(e.path)
+		if err != nil || (info.ModTime().Equal(modTime) && info.Size() == size) {
+			continue
+		}
+		modTime, size = info.ModTime(), info.Size()
+
+		if err := e.Reload(); err != nil {
+			log.Printf("keeping previous rate limit policies: %v", err)
+			continue
+		}
+		log.Printf("reloaded rate limit policies from %s", e.path)
+	}
+}
+
+// Match returns the limit of the first rule the request matches. ok is
+// false when no rule matches or the matching rule has no limit for the
+// caller's tier.
+func (e *Engine) Match(req Request) (decision Decision, ok bool) {
+	config := e.config.Load()
+	who := config.identify(req)
+
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if !rule.matches(req, who) {
+			continue
+		}
+
+		limit, found := rule.Limits[who.tier]
+		tier := who.tier
+		if !found {
+			limit, found = rule.Limits[config.DefaultTier]
+			tier = config.DefaultTier
+		}
+		if !found {
+			return Decision{}, false
+		}
+		return Decision{Rule: rule.Name, Tier: tier, Key: rule.clientKey(req, who), Limit: limit}, true
+	}
+	return Decision{}, false
+}
+
+// identify finds the caller's API key, user and tier. A known API key wins
+// over a token; unknown keys are treated as anonymous.
+func (c *Config) identify(req Request) caller {
+	who := caller{tier: c.DefaultTier}
+
+	if key := req.Header.Get(c.APIKeyHeader); key != "" {
+		if known, ok := c.APIKeys[key]; ok {
+			who.apiKey = key
+			who.user = known.User
+			if known.Tier != "" {
+				who.tier = known.Tier
+			}
+			return who
+		}
+	}
+
+	if c.JWT.Secret == "" {
+		return who
+	}
+	token, found := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		return who
+	}
+	claims, err := parseJWT(token, []byte(c.JWT.Secret))
+	if err != nil {
+		return who
+	}
+	who.user = claimString(claims, c.JWT.UserClaim)
+	if tier := claimString(claims, c.JWT.TierClaim); tier != "" {
+		who.tier = tier
+	}
+	return who
+}
+
+func (r *Rule) matches(req Request, who caller) bool {
+	if len(r.Methods) > 0 && !containsFold(r.Methods, req.Method) {
+		return false
+	}
+	if len(r.Routes) > 0 && !matchRoute(r.Routes, req) {
+		return false
+	}
+	if len(r.APIKeys) > 0 && !contains(r.APIKeys, who.apiKey) {
+		return false
+	}
+	if len(r.Users) > 0 && !contains(r.Users, who.user) {
+		return false
+	}
+	for name, value := range r.Headers {
+		if req.Header.Get(name) != value {
+			return false
+		}
+	}
+	return true
+}
+
+// clientKey is what the rule counts by; a request without it is counted
+// by IP so it cannot dodge the limit by leaving the field out
+func (r *Rule) clientKey(req Request, who caller) string {
+	switch {
+	case r.Key == "api_key" && who.apiKey != "":
+		return "api_key:" + who.apiKey
+	case r.Key == "user" && who.user != "":
+		return "user:" + who.user
+	case strings.HasPrefix(r.Key, "header:"):
+		if value := req.Header.Get(strings.TrimPrefix(r.Key, "header:")); value != "" {
+			return r.Key + "=" + value
+		}
+	}
+	return "ip:" + req.IP
+}
+
+// matchRoute accepts the registered route pattern as written or a glob
+// over the request path
+func matchRoute(routes []string, req Request) bool {
+	for _, route := range routes {
+		if route == req.Route {
+			return true
+		}
+		if ok, _ := path.Match(route, req.Path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package rate_limiter
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimiter caps the number of requests in flight instead of the
+// request rate. The cap follows the backend: it grows while latency stays
+// near the no-load latency and shrinks when latency rises or requests fail,
+// so a slow backend gets less work before it falls over.
+type AdaptiveLimiter struct {
+	Algorithm    LimitAlgorithm
+	MinLimit     int
+	MaxLimit     int
+	SampleWindow time.Duration // the limit is updated once per window
+	MinRTTWindow time.Duration // how long the no-load latency is remembered
+	Mu           sync.Mutex
+
+	limit    float64
+	inFlight int
+
+	// current sample window
+	windowStart time.Time
+	samples     int
+	totalRTT    time.Duration
+	maxInFlight int
+	dropped     bool
+
+	// no-load latency, the lowest of the current and previous MinRTTWindow
+	minRTT     time.Duration
+	prevMinRTT time.Duration
+	minRTTAt   time.Time
+
+	lastRTT  time.Duration
+	accepted uint64
+	rejected uint64
+}
+
+// Sample is what one sample window saw
+type Sample struct {
+	RTT         time.Duration // average latency
+	MinRTT      time.Duration // no-load latency
+	MaxInFlight int
+	Dropped     bool // a request failed in a way that signals overload
+}
+
+// LimitAlgorithm turns the current limit and a sample into the next limit
+type LimitAlgorithm interface {
+	Update(limit float64, sample Sample) float64
+}
+
+// AdaptiveStats is the limiter's current state
+type AdaptiveStats struct {
+	Limit    int
+	InFlight int
+	MinRTT   time.Duration
+	LastRTT  time.Duration
+	Accepted uint64
+	Rejected uint64
+}
+
+func GetAdaptiveLimiter(algorithm LimitAlgorithm, initialLimit int, minLimit int, maxLimit int) *AdaptiveLimiter {
+	now := time.Now()
+	return &AdaptiveLimiter{
+		Algorithm:    algorithm,
+		MinLimit:     minLimit,
+		MaxLimit:     maxLimit,
+		SampleWindow: 100 * time.Millisecond,
+		MinRTTWindow: 30 * time.Second,
+		limit:        float64(initialLimit),
+		windowStart:  now,
+		minRTTAt:     now,
+	}
+}
+
+// Acquire takes a slot if fewer than limit requests are in flight. The
+// caller must call release when the request is done, with dropped set if
+// it failed from overload (timeout, 503).
+func (a *AdaptiveLimiter) Acquire() (release func(dropped bool), ok bool) {
+	a.Mu.Lock()
+	defer a.Mu.Unlock()
+
+	if a.inFlight >= int(a.limit) {
+		a.rejected++
+		return nil, false
+	}
+	a.inFlight++
+	a.accepted++
+	a.maxInFlight = max(a.maxInFlight, a.inFlight)
+
+	start := time.Now()
+	var once sync.Once
+	return func(dropped bool) {
+		once.Do(func() { a.release(time.Since(start), dropped) })
+	}, true
+}
+
+func (a *AdaptiveLimiter) release(rtt time.Duration, dropped bool) {
+	a.Mu.Lock()
+	defer a.Mu.Unlock()
+
+	a.inFlight--
+	a.lastRTT = rtt
+	a.samples++
+	a.totalRTT += rtt
+	a.dropped = a.dropped || dropped
+
+	now := time.Now()
+	// the no-load latency is forgotten slowly, so a backend that got
+	// permanently slower is not compared against its old best forever
+	if now.Sub(a.minRTTAt) >= a.MinRTTWindow {
+		a.prevMinRTT, a.minRTT = a.minRTT, 0
+		a.minRTTAt = now
+	}
+	if a.minRTT == 0 || rtt < a.minRTT {
+		a.minRTT = rtt
+	}
+
+	if now.Sub(a.windowStart) < a.SampleWindow {
+		return
+	}
+
+	sample := Sample{
+		RTT:         a.totalRTT / time.Duration(a.samples),
+		MinRTT:      a.baseline(),
+		MaxInFlight: a.maxInFlight,
+		Dropped:     a.dropped,
+	}
+	a.limit = min(float64(a.MaxLimit), max(float64(a.MinLimit), a.Algorithm.Update(a.limit, sample)))
+
+	a.windowStart = now
+	a.samples = 0
+	a.totalRTT = 0
+	a.maxInFlight = a.inFlight
+	a.dropped = false
+}
+
+func (a *AdaptiveLimiter) baseline() time.Duration {
+	if a.prevMinRTT > 0 && a.prevMinRTT < a.minRTT {
+		return a.prevMinRTT
+	}
+	return a.minRTT
+}
+
+func (a *AdaptiveLimiter) Stats() AdaptiveStats {
+	a.Mu.Lock()
+	defer a.Mu.Unlock()
+
+	return AdaptiveStats{
+		Limit:    int(a.limit),
+		InFlight: a.inFlight,
+		MinRTT:   a.baseline(),
+		LastRTT:  a.lastRTT,
+		Accepted: a.accepted,
+		Rejected: a.rejected,
+	}
+}
+
+// appLimited reports whether the limit was far from reached, in which case
+// latency says nothing about whether more would fit
+func appLimited(limit float64, sample Sample) bool {
+	return float64(sample.MaxInFlight)*2 < limit
+}
+
+// AIMD adds Increase while requests succeed and multiplies by Backoff when
+// one drops or the latency passes Timeout, like TCP Reno
+type AIMD struct {
+	Increase float64
+	Backoff  float64
+	Timeout  time.Duration // 0 reacts to drops only
+}
+
+func (alg AIMD) Update(limit float64, sample Sample) float64 {
+	if sample.Dropped || (alg.Timeout > 0 && sample.RTT > alg.Timeout) {
+		return limit * alg.Backoff
+	}
+	if appLimited(limit, sample) {
+		return limit
+	}
+	return limit + alg.Increase
+}
+
+// Vegas estimates how many requests are queued in the backend from how much
+// slower they are than the no-load latency, like TCP Vegas.
+//
+// Example: limit = 20, MinRTT = 10ms, RTT = 15ms
+// queue = 20 * (1 - 10/15) = 6.7, over Beta = 6, so the limit shrinks
+type Vegas struct {
+	Alpha float64 // grow while fewer than Alpha requests queue
+	Beta  float64 // shrink once more than Beta queue
+}
+
+func (alg Vegas) Update(limit float64, sample Sample) float64 {
+	step := max(1, math.Log10(limit))
+	if sample.Dropped {
+		return limit * 0.9
+	}
+	if sample.MinRTT == 0 || sample.RTT == 0 {
+		return limit
+	}
+
+	queue := limit * (1 - float64(sample.MinRTT)/float64(sample.RTT))
+	switch {
+	case queue >= alg.Beta:
+		return limit - step
+	case queue <= alg.Alpha && !appLimited(limit, sample):
+		return limit + step
+	}
+	return limit
+}
+
+// Gradient scales the limit by how far latency moved from the no-load
+// latency, and adds sqrt(limit) of headroom so it can probe upwards.
+// Tolerance is the slowdown accepted before shrinking, Smoothing how much
+// of each new estimate is taken.
+//
+// Example: limit = 100, MinRTT = 10ms, RTT = 40ms, Tolerance = 2
+// gradient = 2 * 10 / 40 = 0.5, new limit = 100 * 0.5 + 10 = 60
+type Gradient struct {
+	Tolerance float64
+	Smoothing float64
+}
+
+func (alg Gradient) Update(limit float64, sample Sample) float64 {
+	if sample.MinRTT == 0 || sample.RTT == 0 {
+		return limit
+	}
+
+	gradient := min(1, max(0.5, alg.Tolerance*float64(sample.MinRTT)/float64(sample.RTT)))
+	if sample.Dropped {
+		gradient = 0.5
+	}
+	next := limit*gradient + math.Sqrt(limit)
+	if appLimited(limit, sample) {
+		next = min(next, limit)
+	}
+	return limit*(1-alg.Smoothing) + next*alg.Smoothing
+}
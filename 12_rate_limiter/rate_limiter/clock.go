@@ -0,0 +1,49 @@
+package rate_limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is where a limiter reads the time from. The sliding window limiters
+// take one so they can be driven step by step instead of by the wall clock.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SystemClock is the wall clock, used by default
+var SystemClock Clock = systemClock{}
+
+// ManualClock only moves when told to
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// windowStart aligns t down to a multiple of window since the Unix epoch,
+// the same boundaries FixedWindow and the Redis scripts use
+func windowStart(t time.Time, window time.Duration) time.Time {
+	return time.Unix(0, t.UnixNano()/int64(window)*int64(window))
+}
@@ -1,7 +1,6 @@
 package rate_limiter
 
 import (
-	"fmt"
 	"sync"
 	"time"
 )
@@ -11,6 +10,9 @@ type FixedWindow struct {
 	Window time.Duration
 	Counts map[int64]int
 	Mu     sync.Mutex
+
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 func GetFixedWindow(limit int, window time.Duration) *FixedWindow {
@@ -18,6 +20,7 @@ func GetFixedWindow(limit int, window time.Duration) *FixedWindow {
 		Limit:  limit,
 		Window: window,
 		Counts: make(map[int64]int),
+		done:   make(chan struct{}),
 	}
 	// Clean up old windows every window duration
 	go fw.cleanup()
@@ -31,8 +34,6 @@ func (fw *FixedWindow) Allow() bool {
 
 	currentWindow := time.Now().UnixNano() / int64(fw.Window)
 
-	fmt.Println("currentWindow", currentWindow)
-
 	if fw.Counts[currentWindow] < fw.Limit {
 		fw.Counts[currentWindow]++
 		return true
@@ -40,11 +41,27 @@ func (fw *FixedWindow) Allow() bool {
 	return false
 }
 
+func (fw *FixedWindow) Quota() Quota {
+	fw.Mu.Lock()
+	defer fw.Mu.Unlock()
+
+	now := time.Now().UnixNano()
+	currentWindow := now / int64(fw.Window)
+	untilReset := time.Duration((currentWindow+1)*int64(fw.Window) - now)
+	return fixedWindowQuota(fw.Limit, fw.Counts[currentWindow], untilReset)
+}
+
 func (fw *FixedWindow) cleanup() {
 	ticker := time.NewTicker(fw.Window)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-fw.done:
+			return
+		}
+
 		fw.Mu.Lock()
 		currentWindow := time.Now().UnixNano() / int64(fw.Window)
 
@@ -57,3 +74,8 @@ func (fw *FixedWindow) cleanup() {
 		fw.Mu.Unlock()
 	}
 }
+
+// Stop ends the cleanup goroutine, once the window is no longer used
+func (fw *FixedWindow) Stop() {
+	fw.stopOnce.Do(func() { close(fw.done) })
+}
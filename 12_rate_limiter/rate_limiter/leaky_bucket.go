@@ -2,6 +2,7 @@ package rate_limiter
 
 import (
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -9,6 +10,8 @@ type LeakyBucket struct {
 	Capacity    int
 	Bucket      chan struct{}
 	ProcessRate time.Duration
+	done        chan struct{}
+	stopOnce    sync.Once
 }
 
 func GetLeakyBucket(capacity int, processRate time.Duration) *LeakyBucket {
@@ -17,6 +20,7 @@ func GetLeakyBucket(capacity int, processRate time.Duration) *LeakyBucket {
 		Capacity:    capacity,
 		Bucket:      make(chan struct{}, capacity),
 		ProcessRate: processRate,
+		done:        make(chan struct{}),
 	}
 
 	go removeFromBucketWithFixedRate(lb)
@@ -34,6 +38,21 @@ func (lb *LeakyBucket) Allow() bool {
 	}
 }
 
+// Quota counts free slots in the bucket. A full bucket frees one slot
+// within ProcessRate and is empty after one ProcessRate per queued request.
+func (lb *LeakyBucket) Quota() Quota {
+	queued := len(lb.Bucket)
+	quota := Quota{
+		Limit:     lb.Capacity,
+		Remaining: lb.Capacity - queued,
+		Reset:     time.Duration(queued) * lb.ProcessRate,
+	}
+	if quota.Remaining == 0 {
+		quota.RetryAfter = lb.ProcessRate
+	}
+	return quota
+}
+
 func removeFromBucketWithFixedRate(lb *LeakyBucket) {
 	defer func() {
 		r := recover()
@@ -44,11 +63,23 @@ func removeFromBucketWithFixedRate(lb *LeakyBucket) {
 	}()
 
 	ticker := time.NewTicker(lb.ProcessRate)
+	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-ticker.C:
+		case <-lb.done:
+			return
+		}
 		select {
 		case <-lb.Bucket: // Remove one element from the bucket
 		default:
 		}
 	}
 }
+
+// Stop ends the goroutine draining the bucket, once the bucket is no
+// longer used
+func (lb *LeakyBucket) Stop() {
+	lb.stopOnce.Do(func() { close(lb.done) })
+}
@@ -0,0 +1,187 @@
+package rate_limiter
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrQueueFull = errors.New("leaky bucket queue is full")
+	ErrQueueWait = errors.New("leaky bucket queue wait would exceed the maximum")
+)
+
+// LeakyQueue is a leaky bucket that delays requests instead of dropping
+// them. Requests wait in FIFO order and leave one per ProcessRate, so the
+// traffic behind it is smooth. A request is turned away up front when
+// Capacity requests are already waiting or its turn would come after
+// MaxWait.
+type LeakyQueue struct {
+	Capacity    int
+	ProcessRate time.Duration
+	MaxWait     time.Duration
+	Mu          sync.Mutex
+
+	queue       *list.List // of *queued, oldest first
+	lastRelease time.Time
+	dispatching bool
+	stats       LeakyQueueStats
+}
+
+// queued is one request waiting for its turn
+type queued struct {
+	ready    chan struct{} // closed when the request may go
+	enqueued time.Time
+}
+
+// LeakyQueueStats counts what happened to the requests of one queue
+type LeakyQueueStats struct {
+	Depth     int           // requests waiting now
+	Admitted  uint64        // requests let through, queued or not
+	Rejected  uint64        // turned away because the queue was full or too slow
+	Cancelled uint64        // clients that gave up while waiting
+	TotalWait time.Duration // summed over admitted requests
+	MaxWait   time.Duration // longest wait of an admitted request
+}
+
+func GetLeakyQueue(capacity int, processRate time.Duration, maxWait time.Duration) *LeakyQueue {
+	return &LeakyQueue{
+		Capacity:    capacity,
+		ProcessRate: processRate,
+		MaxWait:     maxWait,
+		queue:       list.New(),
+	}
+}
+
+// Wait blocks until the request's turn comes. It returns ErrQueueFull or
+// ErrQueueWait without waiting when the request cannot be served in time,
+// and ctx.Err() if ctx is done first.
+func (lq *LeakyQueue) Wait(ctx context.Context) error {
+	lq.Mu.Lock()
+
+	now := time.Now()
+	// nobody is waiting and the last request left long enough ago
+	if lq.queue.Len() == 0 && now.Sub(lq.lastRelease) >= lq.ProcessRate {
+		lq.lastRelease = now
+		lq.stats.Admitted++
+		lq.Mu.Unlock()
+		return nil
+	}
+
+	if lq.queue.Len() >= lq.Capacity {
+		lq.stats.Rejected++
+		lq.Mu.Unlock()
+		return ErrQueueFull
+	}
+	// Example: 2 waiting, ProcessRate = 100ms, last release 30ms ago
+	// this request leaves at 3 * 100ms - 30ms = 270ms from now
+	turn := lq.lastRelease.Add(time.Duration(lq.queue.Len()+1) * lq.ProcessRate).Sub(now)
+	if turn > lq.MaxWait {
+		lq.stats.Rejected++
+		lq.Mu.Unlock()
+		return ErrQueueWait
+	}
+
+	request := &queued{ready: make(chan struct{}), enqueued: now}
+	element := lq.queue.PushBack(request)
+	if !lq.dispatching {
+		lq.dispatching = true
+		go lq.dispatch()
+	}
+	lq.Mu.Unlock()
+
+	select {
+	case <-request.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	lq.Mu.Lock()
+	defer lq.Mu.Unlock()
+	select {
+	case <-request.ready:
+		// released at the same moment, the slot is spent either way
+	default:
+		lq.queue.Remove(element)
+		lq.stats.Cancelled++
+	}
+	return ctx.Err()
+}
+
+// dispatch releases the oldest request every ProcessRate while any wait.
+// It runs only while the queue is not empty, an idle queue has no goroutine.
+func (lq *LeakyQueue) dispatch() {
+	for {
+		lq.Mu.Lock()
+		if lq.queue.Len() == 0 {
+			lq.dispatching = false
+			lq.Mu.Unlock()
+			return
+		}
+		next := lq.lastRelease.Add(lq.ProcessRate)
+		lq.Mu.Unlock()
+
+		time.Sleep(time.Until(next))
+
+		lq.Mu.Lock()
+		// requests that cancelled during the sleep are already gone
+		if front := lq.queue.Front(); front != nil {
+			request := lq.queue.Remove(front).(*queued)
+			now := time.Now()
+			lq.lastRelease = now
+
+			waited := now.Sub(request.enqueued)
+			lq.stats.Admitted++
+			lq.stats.TotalWait += waited
+			lq.stats.MaxWait = max(lq.stats.MaxWait, waited)
+			close(request.ready)
+		}
+		lq.Mu.Unlock()
+	}
+}
+
+// Allow lets a request through only if it would not have to wait, like a
+// LeakyBucket with no room to queue
+func (lq *LeakyQueue) Allow() bool {
+	lq.Mu.Lock()
+	defer lq.Mu.Unlock()
+
+	now := time.Now()
+	if lq.queue.Len() == 0 && now.Sub(lq.lastRelease) >= lq.ProcessRate {
+		lq.lastRelease = now
+		lq.stats.Admitted++
+		return true
+	}
+	lq.stats.Rejected++
+	return false
+}
+
+// Quota counts free places in the queue. The queue is empty after one
+// ProcessRate per waiting request.
+func (lq *LeakyQueue) Quota() Quota {
+	lq.Mu.Lock()
+	defer lq.Mu.Unlock()
+
+	depth := lq.queue.Len()
+	quota := Quota{
+		Limit:     lq.Capacity,
+		Remaining: lq.Capacity - depth,
+		Reset:     max(0, time.Until(lq.lastRelease.Add(time.Duration(depth)*lq.ProcessRate))),
+	}
+	if quota.Remaining == 0 {
+		quota.RetryAfter = max(0, time.Until(lq.lastRelease.Add(lq.ProcessRate)))
+	}
+	return quota
+}
+
+// Stats returns the queue's counters and current depth
+func (lq *LeakyQueue) Stats() LeakyQueueStats {
+	lq.Mu.Lock()
+	defer lq.Mu.Unlock()
+
+	stats := lq.stats
+	stats.Depth = lq.queue.Len()
+	return stats
+}
@@ -0,0 +1,87 @@
+package rate_limiter
+
+import (
+	"math"
+	"time"
+)
+
+// Quota is how much of its limit a client has left
+type Quota struct {
+	Limit     int
+	Remaining int
+	// Reset is how long until the client has its whole limit again
+	Reset time.Duration
+	// RetryAfter is how long until the next request would be allowed,
+	// zero while Remaining > 0
+	RetryAfter time.Duration
+}
+
+// tokenBucketQuota is the quota of a bucket holding tokens, refilled at
+// ratePerMinute
+func tokenBucketQuota(capacity int, tokens float64, ratePerMinute float64) Quota {
+	quota := Quota{Limit: capacity, Remaining: int(tokens)}
+	if ratePerMinute <= 0 {
+		return quota
+	}
+	quota.Reset = refillTime(float64(capacity)-tokens, ratePerMinute)
+	if tokens < 1 {
+		quota.RetryAfter = refillTime(1-tokens, ratePerMinute)
+	}
+	return quota
+}
+
+func refillTime(tokens float64, ratePerMinute float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(tokens / ratePerMinute * float64(time.Minute)))
+}
+
+// fixedWindowQuota is the quota of a window with count requests that ends
+// in untilReset
+func fixedWindowQuota(limit int, count int, untilReset time.Duration) Quota {
+	quota := Quota{Limit: limit, Remaining: max(0, limit-count)}
+	if count > 0 {
+		quota.Reset = untilReset
+	}
+	if quota.Remaining == 0 {
+		quota.RetryAfter = untilReset
+	}
+	return quota
+}
+
+// slidingWindowQuota is the quota of a sliding window counter elapsed into
+// its current window.
+//
+// Example: limit = 5, window = 10s, prev = 4, curr = 2, elapsed = 5s
+// estimate = 4 * 0.5 + 2 = 4, so one more request fits. After it the
+// estimate is 5 and the client waits until 4 * weight + 3 < 5, which is
+// when weight < 0.5, right after 5s.
+func slidingWindowQuota(limit int, window time.Duration, prev int, curr int, elapsed time.Duration) Quota {
+	weight := float64(window-elapsed) / float64(window)
+	estimate := float64(prev)*weight + float64(curr)
+
+	quota := Quota{Limit: limit, Remaining: max(0, int(math.Ceil(float64(limit)-estimate)))}
+
+	// everything is forgotten once the current count slid out as well
+	switch {
+	case curr > 0:
+		quota.Reset = window - elapsed + window
+	case prev > 0:
+		quota.Reset = window - elapsed
+	}
+
+	if quota.Remaining > 0 {
+		return quota
+	}
+	if curr < limit {
+		// prev * (1 - e/window) + curr < limit, later in this window
+		until := time.Duration(float64(window) * (1 - float64(limit-curr)/float64(prev)))
+		quota.RetryAfter = until - elapsed + time.Millisecond
+	} else {
+		// curr * (1 - e/window) < limit, into the next window
+		until := time.Duration(float64(window) * (1 - float64(limit)/float64(curr)))
+		quota.RetryAfter = window - elapsed + until + time.Millisecond
+	}
+	return quota
+}
@@ -0,0 +1,66 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowQuota(t *testing.T) {
+	const window = 10 * time.Second
+
+	tests := []struct {
+		name       string
+		limit      int
+		prev, curr int
+		elapsed    time.Duration
+		want       Quota
+	}{
+		{
+			name:  "empty",
+			limit: 5,
+			want:  Quota{Limit: 5, Remaining: 5},
+		},
+		{
+			// 4 * 0.5 + 2 = 4
+			name: "room left", limit: 5, prev: 4, curr: 2, elapsed: 5 * time.Second,
+			want: Quota{Limit: 5, Remaining: 1, Reset: 15 * time.Second},
+		},
+		{
+			// 4 * 0.5 + 2.5 rounds the remaining request up
+			name: "fractional estimate", limit: 5, prev: 3, curr: 2, elapsed: 5 * time.Second,
+			want: Quota{Limit: 5, Remaining: 2, Reset: 15 * time.Second},
+		},
+		{
+			name: "only the previous window counts", limit: 5, prev: 4, elapsed: 5 * time.Second,
+			want: Quota{Limit: 5, Remaining: 3, Reset: 5 * time.Second},
+		},
+		{
+			// 4 * 0.8 + 3 = 6.2, fits again once 4 * weight + 3 < 5: after 5s
+			name: "retry later in this window", limit: 5, prev: 4, curr: 3, elapsed: 2 * time.Second,
+			want: Quota{Limit: 5, Remaining: 0, Reset: 18 * time.Second, RetryAfter: 3*time.Second + time.Millisecond},
+		},
+		{
+			name: "retry right after the boundary", limit: 5, prev: 4, curr: 3, elapsed: 5 * time.Second,
+			want: Quota{Limit: 5, Remaining: 0, Reset: 15 * time.Second, RetryAfter: time.Millisecond},
+		},
+		{
+			// the current window is full: wait for it to become the previous
+			name: "retry in the next window", limit: 5, curr: 5, elapsed: 4 * time.Second,
+			want: Quota{Limit: 5, Remaining: 0, Reset: 16 * time.Second, RetryAfter: 6*time.Second + time.Millisecond},
+		},
+		{
+			// 10 * weight < 5 from halfway into the next window
+			name: "over the limit, as the shared Redis count can be", limit: 5, curr: 10,
+			want: Quota{Limit: 5, Remaining: 0, Reset: 20 * time.Second, RetryAfter: 15*time.Second + time.Millisecond},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := slidingWindowQuota(tt.limit, window, tt.prev, tt.curr, tt.elapsed)
+			if got != tt.want {
+				t.Errorf("slidingWindowQuota(%d, %v, %d, %d, %v) = %+v, want %+v",
+					tt.limit, window, tt.prev, tt.curr, tt.elapsed, got, tt.want)
+			}
+		})
+	}
+}
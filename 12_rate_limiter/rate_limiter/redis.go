@@ -0,0 +1,77 @@
+package rate_limiter
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Limiter is what the middlewares need from every algorithm
+type Limiter interface {
+	Allow() bool
+	Quota() Quota
+}
+
+// redisDown remembers whether the last Redis call failed, so an outage is
+// logged once when it starts and once when it ends instead of per request
+var redisDown atomic.Bool
+
+func reportRedisError(err error) {
+	if !redisDown.Swap(true) {
+		log.Printf("redis rate limiter unavailable, using in-memory limits: %v", err)
+	}
+}
+
+func reportRedisOK() {
+	if redisDown.Swap(false) {
+		log.Println("redis rate limiter available again")
+	}
+}
+
+// lastQuota is the quota the last script call returned. Redis limiters
+// report it rather than asking Redis a second time per request; its
+// durations count down from when it was stored.
+type lastQuota struct {
+	mu       sync.Mutex
+	quota    Quota
+	at       time.Time
+	fallback bool // the last call failed, the fallback limiter knows better
+}
+
+func (l *lastQuota) store(quota Quota) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.quota, l.at, l.fallback = quota, time.Now(), false
+}
+
+func (l *lastQuota) useFallback() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.fallback = true
+}
+
+// load returns the stored quota, or initial before the first call
+func (l *lastQuota) load(initial Quota) (quota Quota, fallback bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.fallback {
+		return Quota{}, true
+	}
+	if l.at.IsZero() {
+		return initial, false
+	}
+	elapsed := time.Since(l.at)
+	quota = l.quota
+	quota.Reset = max(0, quota.Reset-elapsed)
+	quota.RetryAfter = max(0, quota.RetryAfter-elapsed)
+	return quota, false
+}
+
+// toFloat reads a number a script returned as a string, Lua numbers become
+// integers on the way out
+func toFloat(v interface{}) float64 {
+	f, _ := strconv.ParseFloat(v.(string), 64)
+	return f
+}
@@ -0,0 +1,112 @@
+package rate_limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// fixedWindowScript counts requests in the current window. One hash per
+// client holds the window number and its count, a new window resets it.
+//
+// KEYS[1] counter hash {window, count}
+// ARGV[1] limit, ARGV[2] window in milliseconds
+// returns {allowed, count, milliseconds until the window resets}
+var fixedWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local size = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local window = math.floor(now / size)
+
+local state = redis.call('HMGET', KEYS[1], 'window', 'count')
+local count = 0
+if tonumber(state[1]) == window then
+	count = tonumber(state[2])
+end
+
+local allowed = 0
+if count < limit then
+	count = count + 1
+	allowed = 1
+	redis.call('HSET', KEYS[1], 'window', window, 'count', count)
+	redis.call('PEXPIRE', KEYS[1], size * 2)
+end
+return {allowed, count, (window + 1) * size - now}
+`)
+
+// RedisFixedWindow is a FixedWindow shared by every instance through Redis.
+// It falls back to an in-memory FixedWindow when Redis fails.
+type RedisFixedWindow struct {
+	client     redis.Cmdable
+	key        string
+	Limit      int
+	Window     time.Duration
+	fallback   *FixedWindow
+	fallbackMu sync.Mutex
+	last       lastQuota
+}
+
+func GetRedisFixedWindow(client redis.Cmdable, key string, limit int, window time.Duration) *RedisFixedWindow {
+	return &RedisFixedWindow{
+		client: client,
+		key:    key,
+		Limit:  limit,
+		Window: window,
+	}
+}
+
+func (fw *RedisFixedWindow) Allow() bool {
+	res, err := fixedWindowScript.Run(fw.client, []string{fw.key}, fw.Limit, fw.Window.Milliseconds()).Result()
+	if err != nil {
+		reportRedisError(err)
+		fw.last.useFallback()
+		return fw.getFallback().Allow()
+	}
+	reportRedisOK()
+	values := res.([]interface{})
+	untilReset := time.Duration(values[2].(int64)) * time.Millisecond
+	fw.last.store(fixedWindowQuota(fw.Limit, int(values[1].(int64)), untilReset))
+	return values[0].(int64) == 1
+}
+
+// Quota reports the count of the last Allow through this handle
+func (fw *RedisFixedWindow) Quota() Quota {
+	quota, fallback := fw.last.load(Quota{Limit: fw.Limit, Remaining: fw.Limit})
+	if fallback {
+		return fw.getFallback().Quota()
+	}
+	return quota
+}
+
+// getFallback creates the in-memory limiter on the first Redis failure
+func (fw *RedisFixedWindow) getFallback() *FixedWindow {
+	fw.fallbackMu.Lock()
+	defer fw.fallbackMu.Unlock()
+	if fw.fallback == nil {
+		fw.fallback = GetFixedWindow(fw.Limit, fw.Window)
+	}
+	return fw.fallback
+}
+
+// Reset deletes the client's shared state, for every instance
+func (fw *RedisFixedWindow) Reset() error {
+	fw.fallbackMu.Lock()
+	if fw.fallback != nil {
+		fw.fallback.Stop()
+	}
+	fw.fallback = nil
+	fw.fallbackMu.Unlock()
+	return fw.client.Del(fw.key).Err()
+}
+
+// Stop ends the cleanup goroutine of the in-memory fallback, if one was
+// created
+func (fw *RedisFixedWindow) Stop() {
+	fw.fallbackMu.Lock()
+	defer fw.fallbackMu.Unlock()
+	if fw.fallback != nil {
+		fw.fallback.Stop()
+	}
+}
@@ -0,0 +1,111 @@
+package rate_limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// slidingWindowScript keeps the counts of the current and the previous
+// aligned window and weights the previous one by how much of it still
+// overlaps the sliding window.
+//
+// KEYS[1] counter hash {window, curr, prev}
+// ARGV[1] limit, ARGV[2] window in milliseconds
+// returns {allowed, weighted count before this request, curr, prev,
+// milliseconds into the current window}
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local size = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+local window = math.floor(now / size)
+
+local state = redis.call('HMGET', KEYS[1], 'window', 'curr', 'prev')
+local last = tonumber(state[1])
+local curr = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+if last == window - 1 then
+	prev = curr
+	curr = 0
+elseif last ~= window then
+	prev = 0
+	curr = 0
+end
+
+local weight = (size - (now - window * size)) / size
+local estimate = prev * weight + curr
+
+local allowed = 0
+if estimate < limit then
+	curr = curr + 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'window', window, 'curr', curr, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], size * 2)
+return {allowed, tostring(estimate), curr, prev, now - window * size}
+`)
+
+// RedisSlidingWindowCounter is a SlidingWindowCounter shared by every
+// instance through Redis. It falls back to an in-memory SlidingWindowCounter
+// when Redis fails.
+type RedisSlidingWindowCounter struct {
+	client     redis.Cmdable
+	key        string
+	Limit      int
+	Window     time.Duration
+	fallback   *SlidingWindowCounter
+	fallbackMu sync.Mutex
+	last       lastQuota
+}
+
+func GetRedisSlidingWindowCounter(client redis.Cmdable, key string, limit int, window time.Duration) *RedisSlidingWindowCounter {
+	return &RedisSlidingWindowCounter{
+		client: client,
+		key:    key,
+		Limit:  limit,
+		Window: window,
+	}
+}
+
+func (swc *RedisSlidingWindowCounter) Allow() bool {
+	res, err := slidingWindowScript.Run(swc.client, []string{swc.key}, swc.Limit, swc.Window.Milliseconds()).Result()
+	if err != nil {
+		reportRedisError(err)
+		swc.last.useFallback()
+		return swc.getFallback().Allow()
+	}
+	reportRedisOK()
+	values := res.([]interface{})
+	elapsed := time.Duration(values[4].(int64)) * time.Millisecond
+	swc.last.store(slidingWindowQuota(swc.Limit, swc.Window, int(values[3].(int64)), int(values[2].(int64)), elapsed))
+	return values[0].(int64) == 1
+}
+
+// Quota reports the counts of the last Allow through this handle
+func (swc *RedisSlidingWindowCounter) Quota() Quota {
+	quota, fallback := swc.last.load(Quota{Limit: swc.Limit, Remaining: swc.Limit})
+	if fallback {
+		return swc.getFallback().Quota()
+	}
+	return quota
+}
+
+// getFallback creates the in-memory limiter on the first Redis failure
+func (swc *RedisSlidingWindowCounter) getFallback() *SlidingWindowCounter {
+	swc.fallbackMu.Lock()
+	defer swc.fallbackMu.Unlock()
+	if swc.fallback == nil {
+		swc.fallback = GetSlidingWindowCounter(swc.Limit, swc.Window)
+	}
+	return swc.fallback
+}
+
+// Reset deletes the client's shared state, for every instance
+func (swc *RedisSlidingWindowCounter) Reset() error {
+	swc.fallbackMu.Lock()
+	swc.fallback = nil
+	swc.fallbackMu.Unlock()
+	return swc.client.Del(swc.key).Err()
+}
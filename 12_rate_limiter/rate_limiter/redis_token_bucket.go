@@ -0,0 +1,105 @@
+package rate_limiter
+
+import (
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// tokenBucketScript refills and takes a token in one step. The clock is
+// Redis TIME, so instances with skewed clocks still share one bucket.
+//
+// KEYS[1] bucket hash {tokens, ts}
+// ARGV[1] capacity, ARGV[2] tokens per millisecond
+// returns {allowed, tokens left}
+var tokenBucketScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + (now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+-- an idle bucket is full again after capacity / rate, then it can go
+if rate > 0 then
+	redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate) + 1000)
+end
+return {allowed, tostring(tokens)}
+`)
+
+// RedisTokenBucket is a TokenBucket whose state lives in Redis, shared by
+// every instance. It falls back to an in-memory TokenBucket when Redis fails.
+type RedisTokenBucket struct {
+	client     redis.Cmdable
+	key        string
+	capacity   int
+	refillRate float64 // tokens per minute
+	fallback   *TokenBucket
+	fallbackMu sync.Mutex
+	last       lastQuota
+}
+
+func GetRedisTokenBucket(client redis.Cmdable, key string, capacity int, refillRatePerMinute float64) *RedisTokenBucket {
+	return &RedisTokenBucket{
+		client:     client,
+		key:        key,
+		capacity:   capacity,
+		refillRate: refillRatePerMinute,
+	}
+}
+
+func (t *RedisTokenBucket) Allow() bool {
+	perMillisecond := t.refillRate / float64(time.Minute/time.Millisecond)
+	res, err := tokenBucketScript.Run(t.client, []string{t.key}, t.capacity, perMillisecond).Result()
+	if err != nil {
+		reportRedisError(err)
+		t.last.useFallback()
+		return t.getFallback().Allow()
+	}
+	reportRedisOK()
+	values := res.([]interface{})
+	t.last.store(tokenBucketQuota(t.capacity, toFloat(values[1]), t.refillRate))
+	return values[0].(int64) == 1
+}
+
+// Quota reports the tokens left after the last Allow through this handle
+func (t *RedisTokenBucket) Quota() Quota {
+	quota, fallback := t.last.load(Quota{Limit: t.capacity, Remaining: t.capacity})
+	if fallback {
+		return t.getFallback().Quota()
+	}
+	return quota
+}
+
+// getFallback creates the in-memory limiter on the first Redis failure
+func (t *RedisTokenBucket) getFallback() *TokenBucket {
+	t.fallbackMu.Lock()
+	defer t.fallbackMu.Unlock()
+	if t.fallback == nil {
+		t.fallback = GetNewTokenBucket(t.capacity, t.refillRate)
+	}
+	return t.fallback
+}
+
+// Reset deletes the client's shared state, for every instance
+func (t *RedisTokenBucket) Reset() error {
+	t.fallbackMu.Lock()
+	t.fallback = nil
+	t.fallbackMu.Unlock()
+	return t.client.Del(t.key).Err()
+}
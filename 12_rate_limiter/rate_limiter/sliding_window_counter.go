@@ -10,17 +10,23 @@ type SlidingWindowCounter struct {
 	Window     time.Duration
 	PrevCount  int
 	CurrCount  int
-	LastWindow time.Time
+	LastWindow time.Time // start of the current window, aligned to a multiple of Window
 	Mu         sync.Mutex
+	clock      Clock
 }
 
 func GetSlidingWindowCounter(limit int, window time.Duration) *SlidingWindowCounter {
+	return GetSlidingWindowCounterWithClock(limit, window, SystemClock)
+}
+
+func GetSlidingWindowCounterWithClock(limit int, window time.Duration, clock Clock) *SlidingWindowCounter {
 	return &SlidingWindowCounter{
 		Limit:      limit,
 		Window:     window,
 		PrevCount:  0,
 		CurrCount:  0,
-		LastWindow: time.Now(),
+		LastWindow: windowStart(clock.Now(), window),
+		clock:      clock,
 	}
 }
 
@@ -34,17 +40,29 @@ func (swc *SlidingWindowCounter) Allow() bool {
 	// lastWindow = 10s (current window started at 10s)
 	// now = 12s
 
-	now := time.Now()
+	now := swc.clock.Now()
 
-	//now.Sub(swc.lastWindow) = 12s - 10s = 2s (time elapsed in current window)
+	// Windows are aligned, so the current one starts at 10s whenever the
+	// first request in it arrives
+	start := windowStart(now, swc.Window)
 
-	elapsed := now.Sub(swc.LastWindow)
-
-	if elapsed >= swc.Window {
+	switch start.Sub(swc.LastWindow) {
+	case 0:
+		// still in the same window
+	case swc.Window:
+		// moved on by one window, the current count becomes the previous
 		swc.PrevCount = swc.CurrCount
 		swc.CurrCount = 0
-		swc.LastWindow = now
+	default:
+		// a whole window passed without requests
+		swc.PrevCount = 0
+		swc.CurrCount = 0
 	}
+	swc.LastWindow = start
+
+	//now.Sub(start) = 12s - 10s = 2s (time elapsed in current window)
+
+	elapsed := now.Sub(start)
 
 	// Calculate weighted request count
 	// formula: prevCount * (remaining time in prev window / Window) + currCount
@@ -59,14 +77,33 @@ func (swc *SlidingWindowCounter) Allow() bool {
 	// weightedCount = prevCount * weight + currCount
 	//               = 4 * 0.8 + 1
 	//               = 3.2 + 1
-	//               = 4 (after int conversion)
+	//               = 4.2
 
-	weightCount := int(swc.PrevCount*int(weight)) + swc.CurrCount
+	weightCount := float64(swc.PrevCount)*weight + float64(swc.CurrCount)
 
-	if weightCount < swc.Limit {
+	if weightCount < float64(swc.Limit) {
 		swc.CurrCount++
 		return true
 	}
 
 	return false
 }
+
+// Quota reports what is left at the current time without counting a request
+func (swc *SlidingWindowCounter) Quota() Quota {
+	swc.Mu.Lock()
+	defer swc.Mu.Unlock()
+
+	now := swc.clock.Now()
+	start := windowStart(now, swc.Window)
+
+	prev, curr := swc.PrevCount, swc.CurrCount
+	switch start.Sub(swc.LastWindow) {
+	case 0:
+	case swc.Window:
+		prev, curr = curr, 0
+	default:
+		prev, curr = 0, 0
+	}
+	return slidingWindowQuota(swc.Limit, swc.Window, prev, curr, now.Sub(start))
+}
@@ -0,0 +1,118 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowCounter(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		created time.Duration // after start, when the limiter is created
+		steps   []step
+	}{
+		{
+			name:  "allows up to the limit in one window",
+			limit: 5,
+			steps: []step{{0, []bool{true, true, true, true, true, false}}},
+		},
+		{
+			// 4 * 0.8 + 0 = 3.2, then 4.2, then 5.2
+			name:  "previous window is weighted by the part still covered",
+			limit: 5,
+			steps: []step{
+				{0, []bool{true, true, true, true}},
+				{12 * time.Second, []bool{true, true, false}},
+			},
+		},
+		{
+			// 4 * 0.2 = 0.8, so 5 more fit: 0.8 + 4 = 4.8
+			name:  "weight shrinks towards the end of the window",
+			limit: 5,
+			steps: []step{
+				{0, []bool{true, true, true, true}},
+				{18 * time.Second, []bool{true, true, true, true, true, false}},
+			},
+		},
+		{
+			name:  "current count becomes the previous on roll-over",
+			limit: 3,
+			steps: []step{
+				{0, []bool{true}},
+				// 1 * 1.0 + 2 = 3
+				{10 * time.Second, []bool{true, true, false}},
+				// the 2 from 10s are now the previous window: 2 * 1.0 + 1 = 3
+				{10 * time.Second, []bool{true, false}},
+				// 2 * 0.5 + 1 = 2, then 3
+				{5 * time.Second, []bool{true, false}},
+			},
+		},
+		{
+			name:  "a skipped window forgets everything",
+			limit: 3,
+			steps: []step{
+				{0, []bool{true, true, true}},
+				{25 * time.Second, []bool{true, true, true, false}},
+			},
+		},
+		{
+			// created at 7s, the window still starts at 0s and ends at 10s
+			name:    "windows are aligned, not started by the first request",
+			limit:   2,
+			created: 7 * time.Second,
+			steps: []step{
+				{0, []bool{true, true, false}},
+				// 10s: new window, 2 * 1.0 = 2
+				{3 * time.Second, []bool{false}},
+				// 15s: 2 * 0.5 = 1
+				{5 * time.Second, []bool{true, false}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(start)
+			clock.Advance(tt.created)
+			swc := GetSlidingWindowCounterWithClock(tt.limit, 10*time.Second, clock)
+			runSteps(t, clock, swc, tt.steps)
+		})
+	}
+}
+
+// RetryAfter must be the first moment a request fits again
+func TestSlidingWindowCounterRetryAfter(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"later in the same window", []step{
+			{0, []bool{true, true, true, true}},
+			{12 * time.Second, []bool{true, true, false}},
+		}},
+		{"in the next window", []step{
+			{3 * time.Second, []bool{true, true, true, true, true, false}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(start)
+			swc := GetSlidingWindowCounterWithClock(5, 10*time.Second, clock)
+			runSteps(t, clock, swc, tt.steps)
+
+			quota := swc.Quota()
+			if quota.Remaining != 0 || quota.RetryAfter <= 0 {
+				t.Fatalf("Quota() = %+v, want no requests left and a RetryAfter", quota)
+			}
+
+			clock.Advance(quota.RetryAfter - 2*time.Millisecond)
+			if swc.Allow() {
+				t.Fatalf("allowed before RetryAfter %v", quota.RetryAfter)
+			}
+			clock.Advance(2 * time.Millisecond)
+			if !swc.Allow() {
+				t.Fatalf("rejected at RetryAfter %v", quota.RetryAfter)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package rate_limiter
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// SlidingWindowLog keeps the timestamp of every allowed request in the last
+// Window. It is exact, unlike SlidingWindowCounter which estimates the
+// previous window, at the cost of up to Limit timestamps per client.
+type SlidingWindowLog struct {
+	Limit  int
+	Window time.Duration
+	Log    []time.Time // allowed requests, oldest first
+	Mu     sync.Mutex
+	clock  Clock
+}
+
+func GetSlidingWindowLog(limit int, window time.Duration) *SlidingWindowLog {
+	return GetSlidingWindowLogWithClock(limit, window, SystemClock)
+}
+
+func GetSlidingWindowLogWithClock(limit int, window time.Duration, clock Clock) *SlidingWindowLog {
+	return &SlidingWindowLog{
+		Limit:  limit,
+		Window: window,
+		Log:    make([]time.Time, 0, limit),
+		clock:  clock,
+	}
+}
+
+func (swl *SlidingWindowLog) Allow() bool {
+	swl.Mu.Lock()
+	defer swl.Mu.Unlock()
+
+	now := swl.clock.Now()
+
+	// Drop the requests that slid out: at now=12s with a 10s window,
+	// everything at or before 2s no longer counts
+	cutoff := now.Add(-swl.Window)
+	expired := sort.Search(len(swl.Log), func(i int) bool {
+		return swl.Log[i].After(cutoff)
+	})
+	swl.Log = append(swl.Log[:0], swl.Log[expired:]...)
+
+	// Rejected requests are not logged, so a client that keeps retrying
+	// gets through as soon as its oldest request slides out
+	if len(swl.Log) < swl.Limit {
+		swl.Log = append(swl.Log, now)
+		return true
+	}
+	return false
+}
+
+// Quota counts the requests still in the window. The next one is allowed
+// when the oldest slides out, the whole limit is back when the newest does.
+func (swl *SlidingWindowLog) Quota() Quota {
+	swl.Mu.Lock()
+	defer swl.Mu.Unlock()
+
+	now := swl.clock.Now()
+	cutoff := now.Add(-swl.Window)
+	expired := sort.Search(len(swl.Log), func(i int) bool {
+		return swl.Log[i].After(cutoff)
+	})
+	active := swl.Log[expired:]
+
+	quota := Quota{Limit: swl.Limit, Remaining: max(0, swl.Limit-len(active))}
+	if len(active) > 0 {
+		quota.Reset = active[len(active)-1].Sub(cutoff)
+	}
+	if quota.Remaining == 0 && len(active) > 0 {
+		quota.RetryAfter = active[len(active)-swl.Limit].Sub(cutoff)
+	}
+	return quota
+}
@@ -0,0 +1,97 @@
+package rate_limiter
+
+import (
+	"testing"
+	"time"
+)
+
+// step advances the clock, then makes one request per entry in want
+type step struct {
+	advance time.Duration
+	want    []bool
+}
+
+// start is aligned to every window the tests use
+var start = time.Unix(1_000, 0)
+
+func TestSlidingWindowLog(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		steps []step
+	}{
+		{
+			name:  "allows up to the limit",
+			limit: 3,
+			steps: []step{{0, []bool{true, true, true, false}}},
+		},
+		{
+			name:  "oldest request slides out after exactly one window",
+			limit: 3,
+			steps: []step{
+				{0, []bool{true, true, true}},
+				{10*time.Second - time.Millisecond, []bool{false}},
+				{time.Millisecond, []bool{true, true, true, false}},
+			},
+		},
+		{
+			name:  "requests slide out one at a time",
+			limit: 2,
+			steps: []step{
+				{0, []bool{true}},
+				{4 * time.Second, []bool{true, false}},
+				{6 * time.Second, []bool{true, false}},
+				{4 * time.Second, []bool{true, false}},
+			},
+		},
+		{
+			name:  "rejected requests are not logged",
+			limit: 1,
+			steps: []step{
+				{0, []bool{true}},
+				{5 * time.Second, []bool{false, false, false}},
+				{5 * time.Second, []bool{true}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewManualClock(start)
+			swl := GetSlidingWindowLogWithClock(tt.limit, 10*time.Second, clock)
+			runSteps(t, clock, swl, tt.steps)
+		})
+	}
+}
+
+func TestSlidingWindowLogQuota(t *testing.T) {
+	clock := NewManualClock(start)
+	swl := GetSlidingWindowLogWithClock(3, 10*time.Second, clock)
+
+	swl.Allow()
+	clock.Advance(2 * time.Second)
+	swl.Allow()
+	swl.Allow()
+	clock.Advance(2 * time.Second)
+
+	want := Quota{Limit: 3, Remaining: 0, Reset: 8 * time.Second, RetryAfter: 6 * time.Second}
+	if got := swl.Quota(); got != want {
+		t.Fatalf("Quota() = %+v, want %+v", got, want)
+	}
+
+	clock.Advance(want.RetryAfter)
+	if !swl.Allow() {
+		t.Error("request after RetryAfter was rejected")
+	}
+}
+
+func runSteps(t *testing.T, clock *ManualClock, limiter Limiter, steps []step) {
+	t.Helper()
+	for i, s := range steps {
+		clock.Advance(s.advance)
+		for j, want := range s.want {
+			if got := limiter.Allow(); got != want {
+				t.Fatalf("step %d, request %d at +%v: Allow() = %v, want %v", i, j, clock.Now().Sub(start), got, want)
+			}
+		}
+	}
+}
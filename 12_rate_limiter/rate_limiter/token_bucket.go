@@ -45,3 +45,12 @@ func (t *TokenBucket) Allow() bool {
 
 	return false
 }
+
+// Quota reports the tokens left after refilling up to now
+func (t *TokenBucket) Quota() Quota {
+	t.Mu.Lock()
+	defer t.Mu.Unlock()
+
+	tokens := min(float64(t.capacity), t.tokens+time.Since(t.LastRefill).Minutes()*t.refillRate)
+	return tokenBucketQuota(t.capacity, tokens, t.refillRate)
+}
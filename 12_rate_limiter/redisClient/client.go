@@ -0,0 +1,31 @@
+package redisClient
+
+import (
+	"log"
+
+	"github.com/go-redis/redis"
+)
+
+var rc *redis.Client
+
+// GetRedisClient returns nil until InitRedisClient succeeded, the
+// middlewares then use the in-memory limiters
+func GetRedisClient() *redis.Client {
+	return rc
+}
+
+func InitRedisClient(addr string, password string) error {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       0, // use default DB
+	})
+	if _, err := client.Ping().Result(); err != nil {
+		client.Close()
+		return err
+	}
+
+	log.Println("redis client successfully connected")
+	rc = client
+	return nil
+}
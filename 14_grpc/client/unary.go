@@ -5,6 +5,9 @@ import (
 	"log"
 
 	pb "github.com/AVVKavvk/grpc/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func createUser(client pb.UserServiceClient, user *pb.CreateUserRequest) error {
@@ -12,6 +15,14 @@ func createUser(client pb.UserServiceClient, user *pb.CreateUserRequest) error {
 	result, err := client.CreateUser(context.Background(), user)
 
 	if err != nil {
+		// the server's rate limiter says when to try again
+		if st := status.Convert(err); st.Code() == codes.ResourceExhausted {
+			for _, detail := range st.Details() {
+				if retry, ok := detail.(*errdetails.RetryInfo); ok {
+					log.Printf("Rate limited, retry in %v", retry.RetryDelay.AsDuration())
+				}
+			}
+		}
 		log.Fatalf("Failed to create user: %v", err)
 		return err
 	}
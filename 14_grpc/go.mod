@@ -5,13 +5,18 @@ go 1.24.0
 toolchain go1.24.12
 
 require (
+	github.com/AVVKavvk/rate_limiter v0.0.0
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251029180050-ab9386a59fda
 	google.golang.org/grpc v1.78.0
 	google.golang.org/protobuf v1.36.11
 )
 
 require (
-	golang.org/x/net v0.47.0 // indirect
-	golang.org/x/sys v0.38.0 // indirect
-	golang.org/x/text v0.31.0 // indirect
-	google.golang.org/genproto/googleapis/rpc v0.0.0-20251029180050-ab9386a59fda // indirect
+	github.com/go-redis/redis v6.15.9+incompatible // indirect
+	golang.org/x/net v0.48.0 // indirect
+	golang.org/x/sys v0.39.0 // indirect
+	golang.org/x/text v0.32.0 // indirect
 )
+
+// the rate limiter lives next to this module in the same repository
+replace github.com/AVVKavvk/rate_limiter => ../12_rate_limiter
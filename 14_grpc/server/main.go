@@ -3,8 +3,14 @@ package main
 import (
 	"log"
 	"net"
+	"os"
+	"strings"
+	"time"
 
 	pb "github.com/AVVKavvk/grpc/proto"
+	"github.com/AVVKavvk/rate_limiter/interceptors"
+	"github.com/AVVKavvk/rate_limiter/keystore"
+	"github.com/AVVKavvk/rate_limiter/rate_limiter"
 	"google.golang.org/grpc"
 )
 
@@ -31,7 +37,17 @@ func main() {
 		log.Fatalf("Failed to listen: %v", err)
 	}
 
-	grpcServer := grpc.NewServer()
+	// rate limit per peer, or per x-api-key metadata for the keys listed in
+	// API_KEYS: bursts of 10 calls, then 1 per second
+	apiKeys := strings.Split(os.Getenv("API_KEYS"), ",")
+	limiter := interceptors.New(interceptors.MetadataKey("x-api-key", interceptors.KnownValues(apiKeys...)), func(string) rate_limiter.Limiter {
+		return rate_limiter.GetNewTokenBucket(10, 60)
+	}, keystore.Config{MaxEntries: 10000, IdleTTL: time.Hour})
+
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(limiter.UnaryServerInterceptor()),
+		grpc.StreamInterceptor(limiter.StreamServerInterceptor()),
+	)
 
 	us := NewUserServer()
 